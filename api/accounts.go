@@ -0,0 +1,372 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/app_msgs"
+	"github.com/jbonadiman/finances-api/internal/audit"
+	"github.com/jbonadiman/finances-api/internal/databases"
+	"github.com/jbonadiman/finances-api/internal/entities"
+	"github.com/jbonadiman/finances-api/internal/environment"
+	"github.com/jbonadiman/finances-api/internal/models"
+	"github.com/jbonadiman/finances-api/internal/parser"
+)
+
+var accountRepository databases.AccountRepository
+
+type accountRequest struct {
+	Name           *string  `json:"name"`
+	Type           *string  `json:"type"`
+	Currency       *string  `json:"currency"`
+	OpeningBalance *float64 `json:"openingBalance"`
+}
+
+func init() {
+	var err error
+
+	accountRepository, err = databases.GetAccountRepository()
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// ManageAccounts lists (GET), creates (POST), updates (PATCH) and deletes
+// (DELETE) the accounts money comes from and goes to. Updates and deletions
+// take the account in the id query parameter. Creating or renaming an
+// account links it to the transactions registered under its name.
+func ManageAccounts(w http.ResponseWriter, r *http.Request) {
+	if !authenticate(w, r) {
+		return
+	}
+
+	if accountRepository == nil {
+		app_msgs.SendNotImplemented(&w, databases.ErrMongoRequired.Error())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		listAccounts(w)
+	case http.MethodPost:
+		createAccount(w, r)
+	case http.MethodPatch:
+		updateAccount(w, r)
+	case http.MethodDelete:
+		deleteAccount(w, r)
+	default:
+		app_msgs.SendMethodNotAllowed(
+			&w,
+			http.MethodGet,
+			http.MethodPost,
+			http.MethodPatch,
+			http.MethodDelete,
+		)
+	}
+}
+
+func listAccounts(w http.ResponseWriter) {
+	accounts, err := accountRepository.GetAllAccounts()
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	app_msgs.SendJSON(&w, accounts, http.StatusOK)
+}
+
+func createAccount(w http.ResponseWriter, r *http.Request) {
+	var request accountRequest
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody(err.Error()))
+		return
+	}
+
+	if request.Name == nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("name is required"))
+		return
+	}
+
+	account := entities.Account{
+		ID:   primitive.NewObjectID(),
+		Type: entities.AccountChecking,
+	}
+
+	if !applyAccountRequest(w, &account, &request) {
+		return
+	}
+
+	err = accountRepository.StoreAccount(&account)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	linkAccountTransactions(&account, "", requestUser(r))
+
+	app_msgs.SendJSON(&w, account, http.StatusCreated)
+}
+
+func updateAccount(w http.ResponseWriter, r *http.Request) {
+	account, ok := findAccount(w, r.URL.Query().Get("id"))
+	if !ok {
+		return
+	}
+
+	var request accountRequest
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody(err.Error()))
+		return
+	}
+
+	previousName := account.Name
+
+	if !applyAccountRequest(w, account, &request) {
+		return
+	}
+
+	if account.Name != previousName && !checkTaskListAccount(w, previousName) {
+		return
+	}
+
+	err = accountRepository.UpdateAccount(account)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	if account.Name != previousName {
+		linkAccountTransactions(account, previousName, requestUser(r))
+	}
+
+	app_msgs.SendJSON(&w, account, http.StatusOK)
+}
+
+func deleteAccount(w http.ResponseWriter, r *http.Request) {
+	account, ok := findAccount(w, r.URL.Query().Get("id"))
+	if !ok {
+		return
+	}
+
+	if !checkTaskListAccount(w, account.Name) {
+		return
+	}
+
+	page, err := transactionRepository.QueryTransactions(
+		models.TransactionQuery{AccountID: account.ID, PageSize: 1},
+	)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	if page.Total > 0 {
+		app_msgs.SendConflict(
+			&w,
+			fmt.Sprintf(
+				"error: account %q still has %v transactions",
+				account.Name,
+				page.Total,
+			),
+		)
+		return
+	}
+
+	err = accountRepository.DeleteAccount(account.ID)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyAccountRequest validates the fields present in the request and sets
+// them on the account.
+func applyAccountRequest(
+	w http.ResponseWriter,
+	account *entities.Account,
+	request *accountRequest,
+) bool {
+	if request.Name != nil {
+		name := strings.TrimSpace(*request.Name)
+		if name == "" {
+			app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("name must not be empty"))
+			return false
+		}
+
+		if name != account.Name && !checkAccountName(w, name) {
+			return false
+		}
+
+		account.Name = name
+	}
+
+	if request.Type != nil {
+		if !isAccountType(*request.Type) {
+			app_msgs.SendBadRequest(
+				&w,
+				app_msgs.InvalidBody(
+					"type must be one of "+strings.Join(entities.AccountTypes, ", "),
+				),
+			)
+			return false
+		}
+
+		account.Type = *request.Type
+	}
+
+	if request.Currency != nil {
+		if !parser.IsCurrency(*request.Currency) {
+			app_msgs.SendBadRequest(
+				&w,
+				app_msgs.InvalidBody("currency must be a three letter code, such as BRL"),
+			)
+			return false
+		}
+
+		account.Currency = *request.Currency
+	}
+
+	if request.OpeningBalance != nil {
+		account.OpeningBalance = *request.OpeningBalance
+	}
+
+	return true
+}
+
+// checkTaskListAccount reports a conflict when a list of TASK_LISTS ingests
+// into the account, since the lists name their account and would no longer
+// find it.
+func checkTaskListAccount(w http.ResponseWriter, name string) bool {
+	for _, list := range environment.TaskLists {
+		if list.Account == name {
+			app_msgs.SendConflict(
+				&w,
+				fmt.Sprintf(
+					"error: the account %q is used by the task list %q, change %v first",
+					name,
+					list.ID,
+					environment.TaskListsKey,
+				),
+			)
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkAccountName reports a conflict when another account already has the
+// name.
+func checkAccountName(w http.ResponseWriter, name string) bool {
+	accounts, err := accountRepository.GetAllAccounts()
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return false
+	}
+
+	for _, a := range *accounts {
+		if a.Name == name {
+			app_msgs.SendConflict(
+				&w,
+				fmt.Sprintf("error: there is already an account named %q", name),
+			)
+			return false
+		}
+	}
+
+	return true
+}
+
+func isAccountType(accountType string) bool {
+	for _, t := range entities.AccountTypes {
+		if t == accountType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// linkAccountTransactions points the transactions registered under the
+// account name, or its previous name, to the account on behalf of the user.
+// Failing to do so is only logged, the transactions are linked again on the
+// next rename.
+func linkAccountTransactions(account *entities.Account, previousName string, user string) {
+	transactions, err := accountTransactions(account, previousName)
+	if err != nil {
+		log.Printf("could not link transactions to account %q: %v\n", account.Name, err)
+		return
+	}
+
+	changed, err := transactionRepository.LinkAccount(account, previousName)
+	if err != nil {
+		log.Printf("could not link transactions to account %q: %v\n", account.Name, err)
+		return
+	}
+
+	audit.Record(
+		auditRepository,
+		entities.AuditSourceAPI,
+		user,
+		audit.Changes(transactions, func(t *entities.Transaction) {
+			t.Account, t.AccountID = account.Name, account.ID
+		})...,
+	)
+
+	log.Printf("linked %v transactions to account %q\n", changed, account.Name)
+}
+
+// accountTransactions returns the transactions LinkAccount changes, as they
+// are before it does, so the change can be audited.
+func accountTransactions(account *entities.Account, previousName string) (
+	[]entities.Transaction,
+	error,
+) {
+	all, err := transactionRepository.GetAllTransactions()
+	if err != nil {
+		return nil, err
+	}
+
+	var transactions []entities.Transaction
+	for _, t := range *all {
+		if t.AccountID == account.ID || t.Account == account.Name ||
+			(previousName != "" && t.Account == previousName) {
+			transactions = append(transactions, t)
+		}
+	}
+
+	return transactions, nil
+}
+
+func findAccount(w http.ResponseWriter, id string) (*entities.Account, bool) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidID(id))
+		return nil, false
+	}
+
+	account, err := accountRepository.GetAccount(objectID)
+	if errors.Is(err, databases.ErrNotFound) {
+		app_msgs.SendNotFound(&w, app_msgs.NotFound("account", id))
+		return nil, false
+	}
+
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return nil, false
+	}
+
+	return account, true
+}
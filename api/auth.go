@@ -70,3 +70,28 @@ func StoreToken(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte("Token stored successfully!"))
 	return
 }
+
+// authenticate checks the basic auth credentials of the request, answering
+// it as unauthorized when they do not match.
+func authenticate(w http.ResponseWriter, r *http.Request) bool {
+	user, password, ok := r.BasicAuth()
+
+	if !ok || !redisClient.CompareAuthentication(user, password) {
+		log.Printf(
+			"non-authenticated call with user:password: %q\n",
+			user+":"+password,
+		)
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte("Unauthorized request"))
+		return false
+	}
+
+	return true
+}
+
+// requestUser returns the user the request authenticated as, recorded as
+// who made the changes it causes.
+func requestUser(r *http.Request) string {
+	user, _, _ := r.BasicAuth()
+	return user
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jbonadiman/finances-api/internal/app_msgs"
+	"github.com/jbonadiman/finances-api/internal/databases"
+	"github.com/jbonadiman/finances-api/internal/entities"
+	"github.com/jbonadiman/finances-api/internal/models"
+)
+
+// GetBalances reports the running balance of each account over time, its
+// opening balance plus every transaction up to each period. The optional
+// query parameters are:
+//
+//	interval    day or month (default)
+//	from, to    limit the history to these dates, same formats as QueryTransactions
+//	accountId   report a single account
+//
+// Transactions before from still count towards the balance, they are only
+// left out of the history.
+func GetBalances(w http.ResponseWriter, r *http.Request) {
+	if !authenticate(w, r) {
+		return
+	}
+
+	if accountRepository == nil {
+		app_msgs.SendNotImplemented(&w, databases.ErrMongoRequired.Error())
+		return
+	}
+
+	query, err := parseTransactionQuery(r.URL.Query())
+	if err != nil {
+		app_msgs.SendBadRequest(&w, err.Error())
+		return
+	}
+
+	interval := r.URL.Query().Get("interval")
+	if interval == "" {
+		interval = models.IntervalMonth
+	}
+
+	layout, ok := models.IntervalLayouts[interval]
+	if !ok {
+		app_msgs.SendBadRequest(
+			&w,
+			app_msgs.InvalidQueryParameter("interval", interval, "must be day or month"),
+		)
+		return
+	}
+
+	accounts, err := balanceAccounts(query)
+	if errors.Is(err, databases.ErrNotFound) {
+		app_msgs.SendNotFound(&w, app_msgs.NotFound("account", query.AccountID.Hex()))
+		return
+	}
+
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	from := query.From
+	query.From = time.Time{}
+
+	movements, err := transactionRepository.AccountMovements(*query, interval)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	balances := make([]models.AccountBalance, 0, len(accounts))
+	for _, account := range accounts {
+		balance := models.RunningBalance(account, movements)
+
+		if !from.IsZero() {
+			balance.History = historySince(balance.History, from.UTC().Format(layout))
+		}
+
+		balances = append(balances, balance)
+	}
+
+	app_msgs.SendJSON(&w, balances, http.StatusOK)
+}
+
+// balanceAccounts returns the account asked for in the query, or every
+// account when none was.
+func balanceAccounts(query *models.TransactionQuery) ([]entities.Account, error) {
+	if !query.AccountID.IsZero() {
+		account, err := accountRepository.GetAccount(query.AccountID)
+		if err != nil {
+			return nil, err
+		}
+
+		return []entities.Account{*account}, nil
+	}
+
+	accounts, err := accountRepository.GetAllAccounts()
+	if err != nil {
+		return nil, err
+	}
+
+	return *accounts, nil
+}
+
+func historySince(history []models.BalancePoint, period string) []models.BalancePoint {
+	for i, point := range history {
+		if point.Period >= period {
+			return history[i:]
+		}
+	}
+
+	return history[len(history):]
+}
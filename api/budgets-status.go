@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jbonadiman/finances-api/internal/app_msgs"
+	"github.com/jbonadiman/finances-api/internal/databases"
+	"github.com/jbonadiman/finances-api/internal/entities"
+	"github.com/jbonadiman/finances-api/internal/models"
+)
+
+// GetBudgetStatus compares the spending of a month against each of its
+// budgets, reporting the percentage used and the spending projected to the
+// end of the month. The month query parameter (2021-02) defaults to the
+// current one.
+func GetBudgetStatus(w http.ResponseWriter, r *http.Request) {
+	if !authenticate(w, r) {
+		return
+	}
+
+	if budgetRepository == nil {
+		app_msgs.SendNotImplemented(&w, databases.ErrMongoRequired.Error())
+		return
+	}
+
+	now := time.Now().UTC()
+
+	month := r.URL.Query().Get("month")
+	if month == "" {
+		month = now.Format(models.MonthLayout)
+	}
+
+	if !isMonth(month) {
+		app_msgs.SendBadRequest(
+			&w,
+			app_msgs.InvalidQueryParameter("month", month, "must be a month (2021-02)"),
+		)
+		return
+	}
+
+	statuses, err := budgetStatuses(month, now)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	app_msgs.SendJSON(&w, statuses, http.StatusOK)
+}
+
+// budgetStatuses compares the spending of the month against its budgets.
+func budgetStatuses(month string, now time.Time) ([]models.BudgetStatus, error) {
+	previousMonth, err := models.PreviousMonth(month)
+	if err != nil {
+		return nil, err
+	}
+
+	budgets, err := budgetRepository.GetBudgets(previousMonth, month)
+	if err != nil {
+		return nil, err
+	}
+
+	previousBudgets := make(map[string]*entities.Budget)
+	var current []entities.Budget
+	rollover := false
+
+	for i, b := range *budgets {
+		if b.Month == month {
+			current = append(current, b)
+			rollover = rollover || b.Rollover
+		} else {
+			previousBudgets[b.Scope+":"+b.Name] = &(*budgets)[i]
+		}
+	}
+
+	statuses := make([]models.BudgetStatus, 0, len(current))
+	if len(current) == 0 {
+		return statuses, nil
+	}
+
+	spending, err := monthSpending(month)
+	if err != nil {
+		return nil, err
+	}
+
+	var previousSpending models.Spending
+	if rollover {
+		previousSpending, err = monthSpending(previousMonth)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range current {
+		budget := &current[i]
+		previous := previousBudgets[budget.Scope+":"+budget.Name]
+
+		statuses = append(
+			statuses,
+			models.NewBudgetStatus(
+				budget,
+				spending.Of(budget),
+				previous,
+				previousSpending.Of(budget),
+				now,
+			),
+		)
+	}
+
+	return statuses, nil
+}
+
+func monthSpending(month string) (models.Spending, error) {
+	from, err := time.Parse(models.MonthLayout, month)
+	if err != nil {
+		return models.Spending{}, err
+	}
+
+	summary, err := transactionRepository.SummarizeTransactions(from, from.AddDate(0, 1, 0))
+	if err != nil {
+		return models.Spending{}, err
+	}
+
+	return models.SpendingOf(summary), nil
+}
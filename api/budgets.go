@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/app_msgs"
+	"github.com/jbonadiman/finances-api/internal/databases"
+	"github.com/jbonadiman/finances-api/internal/entities"
+	"github.com/jbonadiman/finances-api/internal/models"
+)
+
+var budgetRepository databases.BudgetRepository
+
+type budgetRequest struct {
+	Scope    string  `json:"scope"`
+	Name     string  `json:"name"`
+	Month    string  `json:"month"`
+	Limit    float64 `json:"limit"`
+	Rollover bool    `json:"rollover"`
+}
+
+func init() {
+	var err error
+
+	budgetRepository, err = databases.GetBudgetRepository()
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// ManageBudgets lists (GET) the budgets of the month query parameter, or
+// every budget without it, sets (PUT) the budget of a subcategory or
+// category in a month, replacing the one already set, and deletes (DELETE)
+// the budget in the id query parameter. Months are written as 2021-02.
+func ManageBudgets(w http.ResponseWriter, r *http.Request) {
+	if !authenticate(w, r) {
+		return
+	}
+
+	if budgetRepository == nil || subcategoryRepository == nil || categoryRepository == nil {
+		app_msgs.SendNotImplemented(&w, databases.ErrMongoRequired.Error())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		listBudgets(w, r)
+	case http.MethodPut:
+		setBudget(w, r)
+	case http.MethodDelete:
+		deleteBudget(w, r)
+	default:
+		app_msgs.SendMethodNotAllowed(
+			&w,
+			http.MethodGet,
+			http.MethodPut,
+			http.MethodDelete,
+		)
+	}
+}
+
+func listBudgets(w http.ResponseWriter, r *http.Request) {
+	var months []string
+
+	if month := r.URL.Query().Get("month"); month != "" {
+		if !isMonth(month) {
+			app_msgs.SendBadRequest(
+				&w,
+				app_msgs.InvalidQueryParameter("month", month, "must be a month (2021-02)"),
+			)
+			return
+		}
+
+		months = append(months, month)
+	}
+
+	budgets, err := budgetRepository.GetBudgets(months...)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	app_msgs.SendJSON(&w, budgets, http.StatusOK)
+}
+
+func setBudget(w http.ResponseWriter, r *http.Request) {
+	var request budgetRequest
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody(err.Error()))
+		return
+	}
+
+	budget := entities.Budget{
+		ID:       primitive.NewObjectID(),
+		Scope:    request.Scope,
+		Name:     strings.TrimSpace(request.Name),
+		Month:    request.Month,
+		Limit:    request.Limit,
+		Rollover: request.Rollover,
+	}
+
+	if !validateBudget(w, &budget) {
+		return
+	}
+
+	err = budgetRepository.SetBudget(&budget)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	app_msgs.SendJSON(&w, budget, http.StatusOK)
+}
+
+func deleteBudget(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidID(id))
+		return
+	}
+
+	err = budgetRepository.DeleteBudget(objectID)
+	if errors.Is(err, databases.ErrNotFound) {
+		app_msgs.SendNotFound(&w, app_msgs.NotFound("budget", id))
+		return
+	}
+
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func validateBudget(w http.ResponseWriter, budget *entities.Budget) bool {
+	if !isMonth(budget.Month) {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("month must be a month (2021-02)"))
+		return false
+	}
+
+	if budget.Limit < 0 {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("limit must not be negative"))
+		return false
+	}
+
+	switch budget.Scope {
+	case entities.BudgetSubcategory:
+		return subcategoryExists(w, budget.Name)
+	case entities.BudgetCategory:
+		return categoryExists(w, budget.Name)
+	default:
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("scope must be subcategory or category"))
+		return false
+	}
+}
+
+func categoryExists(w http.ResponseWriter, name string) bool {
+	categories, err := categoryRepository.GetAllCategories()
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return false
+	}
+
+	for _, c := range *categories {
+		if c.Name == name {
+			return true
+		}
+	}
+
+	app_msgs.SendBadRequest(
+		&w,
+		app_msgs.InvalidBody("there is no category named "+name),
+	)
+	return false
+}
+
+func isMonth(month string) bool {
+	_, err := time.Parse(models.MonthLayout, month)
+	return err == nil
+}
@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/app_msgs"
+	"github.com/jbonadiman/finances-api/internal/audit"
+	"github.com/jbonadiman/finances-api/internal/databases"
+	"github.com/jbonadiman/finances-api/internal/entities"
+	"github.com/jbonadiman/finances-api/internal/models"
+)
+
+var categoryRepository databases.CategoryRepository
+
+type categoryRequest struct {
+	Name string `json:"name"`
+}
+
+func init() {
+	var err error
+
+	categoryRepository, err = databases.GetCategoryRepository()
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// ManageCategories lists (GET), creates (POST), renames (PATCH) and deletes
+// (DELETE) categories, the parents of subcategories. Renames and deletions
+// take the category in the id query parameter. Renaming a category also
+// renames it in the transactions of its subcategories.
+func ManageCategories(w http.ResponseWriter, r *http.Request) {
+	if !authenticate(w, r) {
+		return
+	}
+
+	if categoryRepository == nil || subcategoryRepository == nil {
+		app_msgs.SendNotImplemented(&w, databases.ErrMongoRequired.Error())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		listCategories(w)
+	case http.MethodPost:
+		createCategory(w, r)
+	case http.MethodPatch:
+		renameCategory(w, r)
+	case http.MethodDelete:
+		deleteCategory(w, r)
+	default:
+		app_msgs.SendMethodNotAllowed(
+			&w,
+			http.MethodGet,
+			http.MethodPost,
+			http.MethodPatch,
+			http.MethodDelete,
+		)
+	}
+}
+
+func listCategories(w http.ResponseWriter) {
+	categories, err := categoryRepository.GetAllCategories()
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	app_msgs.SendJSON(&w, categories, http.StatusOK)
+}
+
+func createCategory(w http.ResponseWriter, r *http.Request) {
+	name, ok := decodeCategoryName(w, r)
+	if !ok {
+		return
+	}
+
+	category := entities.Category{
+		ID:   primitive.NewObjectID(),
+		Name: name,
+	}
+
+	err := categoryRepository.StoreCategory(&category)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	app_msgs.SendJSON(&w, category, http.StatusCreated)
+}
+
+func renameCategory(w http.ResponseWriter, r *http.Request) {
+	category, ok := findCategory(w, r.URL.Query().Get("id"))
+	if !ok {
+		return
+	}
+
+	name, ok := decodeCategoryName(w, r)
+	if !ok {
+		return
+	}
+
+	category.Name = name
+
+	err := categoryRepository.UpdateCategory(category)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	subcategories, err := subcategoriesOf(category.ID)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	transactions, err := subcategoryTransactions(subcategories)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	changed, err := transactionRepository.SetCategory(subcategories, category.Name)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	audit.Record(
+		auditRepository,
+		entities.AuditSourceAPI,
+		requestUser(r),
+		audit.Changes(transactions, func(t *entities.Transaction) {
+			t.Category = category.Name
+		})...,
+	)
+
+	log.Printf(
+		"renamed category to %q in %v transactions\n",
+		category.Name,
+		changed,
+	)
+
+	app_msgs.SendJSON(&w, category, http.StatusOK)
+}
+
+func deleteCategory(w http.ResponseWriter, r *http.Request) {
+	category, ok := findCategory(w, r.URL.Query().Get("id"))
+	if !ok {
+		return
+	}
+
+	subcategories, err := subcategoriesOf(category.ID)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	if len(subcategories) > 0 {
+		app_msgs.SendConflict(
+			&w,
+			fmt.Sprintf(
+				"error: category %q still has the subcategories %v",
+				category.Name,
+				strings.Join(subcategories, ", "),
+			),
+		)
+		return
+	}
+
+	err = categoryRepository.DeleteCategory(category.ID)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decodeCategoryName(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var request categoryRequest
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody(err.Error()))
+		return "", false
+	}
+
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("name is required"))
+		return "", false
+	}
+
+	return name, true
+}
+
+func findCategory(w http.ResponseWriter, id string) (*entities.Category, bool) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidID(id))
+		return nil, false
+	}
+
+	category, err := categoryRepository.GetCategory(objectID)
+	if errors.Is(err, databases.ErrNotFound) {
+		app_msgs.SendNotFound(&w, app_msgs.NotFound("category", id))
+		return nil, false
+	}
+
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return nil, false
+	}
+
+	return category, true
+}
+
+// subcategoriesOf returns the names of the subcategories of a category.
+func subcategoriesOf(categoryID primitive.ObjectID) ([]string, error) {
+	subcategories, err := subcategoryRepository.GetAllSubcategories()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, s := range *subcategories {
+		if s.CategoryID == categoryID {
+			names = append(names, s.Name)
+		}
+	}
+
+	return names, nil
+}
+
+// categoriesBySubcategory maps the name of each subcategory to the name of
+// its category, leaving out subcategories without one.
+func categoriesBySubcategory(
+	subcategories []entities.Subcategory,
+) (map[string]string, error) {
+	categories, err := categoryRepository.GetAllCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	return models.CategoriesBySubcategory(subcategories, *categories), nil
+}
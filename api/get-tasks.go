@@ -3,36 +3,48 @@ package handler
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
-	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/oauth2"
 
 	"github.com/jbonadiman/finances-api/internal/app_msgs"
-	"github.com/jbonadiman/finances-api/internal/databases/mongodb"
+	"github.com/jbonadiman/finances-api/internal/audit"
+	"github.com/jbonadiman/finances-api/internal/categorizer"
+	"github.com/jbonadiman/finances-api/internal/databases"
 	redisDB "github.com/jbonadiman/finances-api/internal/databases/redis"
 	"github.com/jbonadiman/finances-api/internal/entities"
 	"github.com/jbonadiman/finances-api/internal/environment"
+	"github.com/jbonadiman/finances-api/internal/graph"
 	"github.com/jbonadiman/finances-api/internal/models"
+	"github.com/jbonadiman/finances-api/internal/parser"
+	"github.com/jbonadiman/finances-api/internal/webhooks"
 )
 
 const (
-	BaseUrl       = "https://graph.microsoft.com/v1.0/me/todo/lists/"
-	FetchTasksUrl = BaseUrl + "%v/tasks?$filter=status%%20eq%%20'notStarted'"
-	AlterTaskUrl  = BaseUrl + "%v/tasks/%v"
+	BaseUrl           = "https://graph.microsoft.com/v1.0/me/todo/lists/"
+	FetchTasksUrl     = BaseUrl + "%v/tasks?$filter=status%%20eq%%20'notStarted'&$top=%v"
+	BatchTaskUrl      = "/me/todo/lists/%v/tasks/%v"
+	CreateTaskUrl     = BaseUrl + "%v/tasks"
 	InvalidTaskSymbol = "⚠"
+
+	// IngestionTimeOut is the time budget of the calls to Graph during an
+	// ingestion, leaving room for the rest within the function max duration
+	IngestionTimeOut = 8 * time.Second
+
+	// BudgetAlertTimeOut is the time budget of raising budget alerts after
+	// an ingestion, apart from its own so a slow ingestion leaves them some
+	BudgetAlertTimeOut = 2 * time.Second
 )
 
 var (
-	mongoClient *mongodb.DB
-	redisClient *redisDB.DB
+	transactionRepository databases.TransactionRepository
+	redisClient           *redisDB.DB
 
 	httpClient *http.Client
 
@@ -40,15 +52,11 @@ var (
 	tokenSource oauth2.TokenSource
 )
 
-type taskList struct {
-	Value []models.Task `json:"value"`
-}
-
 func init() {
 	var err error
 
-	log.Println("connecting to mongoDB...")
-	mongoClient, err = mongodb.GetDB()
+	log.Printf("connecting to %v storage...\n", environment.StorageBackend)
+	transactionRepository, err = databases.GetTransactionRepository()
 	if err != nil {
 		log.Fatalf(err.Error())
 	}
@@ -70,70 +78,123 @@ func init() {
 	httpClient = msConfig.Client(ctx, token)
 }
 
+// FetchTasks stores a transaction for each pending task in the configured
+// To Do lists and marks those tasks as completed. The lists are ingested
+// concurrently, each transaction belonging to the account of its list.
+// Tasks that cannot be parsed are flagged as invalid without keeping the
+// valid ones from being stored. The response lists the outcome of every
+// task, by list.
+//
+// With the dryRun=true query parameter nothing is stored nor changed in To
+// Do, the response lists the transactions that would be stored instead.
 func FetchTasks(w http.ResponseWriter, r *http.Request) {
-	user, password, ok := r.BasicAuth()
-
-	if !ok || !redisClient.CompareAuthentication(user, password) {
-		log.Printf(
-			"non-authenticated call with user:password: %q\n",
-			user+":"+password,
-		)
-		w.WriteHeader(http.StatusUnauthorized)
-		_, _ = w.Write([]byte("Unauthorized request"))
+	if !authenticate(w, r) {
 		return
 	}
 
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+	user := requestUser(r)
+
 	storeRefreshedToken()
 
-	tasks, err := getNotStartedTasks()
-	if err != nil {
-		log.Println("an error occurred while retrieving tasks...")
-		app_msgs.SendInternalError(&w, err.Error())
-		return
+	ctx, cancel := context.WithTimeout(r.Context(), IngestionTimeOut)
+	defer cancel()
+
+	graphClient := graph.NewClient(httpClient)
+	catalog := loadCatalog()
+
+	result := models.IngestionResult{
+		DryRun: dryRun,
+		Lists:  make([]models.ListIngestion, len(environment.TaskLists)),
 	}
 
-	if len(*tasks) == 0 {
-		_, _ = w.Write([]byte("could not find any tasks to be stored"))
-		return
+	wg := sync.WaitGroup{}
+
+	for i, list := range environment.TaskLists {
+		wg.Add(1)
+		go func(index int, l environment.TaskList) {
+			defer wg.Done()
+			result.Lists[index] = ingestList(ctx, graphClient, l, catalog, dryRun, user)
+		}(i, list)
 	}
 
-	transactions, errList := parseTasks(tasks)
-	if len(errList) > 0 {
-		var errorsAsString []string
+	wg.Wait()
 
-		for _, e := range errList {
-			errorsAsString = append(errorsAsString, e.Error())
-		}
+	for _, l := range result.Lists {
+		result.Stored.Add(l.Stored)
+	}
 
-		errorsMsg := strings.Join(errorsAsString, "\n")
+	if !dryRun && result.Stored.Stored() > 0 {
+		alertCtx, cancelAlerts := context.WithTimeout(r.Context(), BudgetAlertTimeOut)
+		raiseBudgetAlerts(alertCtx, graphClient, time.Now().UTC())
+		cancelAlerts()
+	}
 
-		log.Println("could not parse all tasks:")
-		log.Println(errorsMsg)
-		return
+	if !dryRun {
+		webhooks.Publish(webhookRepository, entities.EventIngestionFinished, result)
 	}
 
-	count, err := storeTransaction(transactions)
-	if err != nil {
-		log.Println("an error occurred while storing transactions...")
-		app_msgs.SendInternalError(&w, err.Error())
-		return
+	log.Printf(
+		"ingestion finished: %v stored, %v invalid, %v skipped, %v failed to store, %v failed to complete\n",
+		result.Count(models.TaskStored),
+		result.Count(models.TaskInvalid),
+		result.Count(models.TaskSkipped),
+		result.Count(models.TaskFailedToStore),
+		result.Count(models.TaskFailedToComplete),
+	)
+
+	status := http.StatusOK
+	if result.Failed() {
+		status = http.StatusInternalServerError
 	}
 
-	err = markTasksAsCompleted(tasks)
+	app_msgs.SendJSON(&w, result, status)
+}
+
+func ingestList(
+	ctx context.Context,
+	graphClient *graph.Client,
+	list environment.TaskList,
+	catalog *catalog,
+	dryRun bool,
+	user string,
+) models.ListIngestion {
+	result := models.ListIngestion{
+		ListID:  list.ID,
+		Account: list.Account,
+		Tasks:   make([]models.TaskOutcome, 0),
+	}
+
+	tasks, pages, err := getNotStartedTasks(ctx, graphClient, list.ID)
+	result.Pages = pages
 	if err != nil {
-		app_msgs.SendInternalError(
-			&w,
-			app_msgs.ErrorCompletingTasks(err.Error()),
-		)
-		return
+		log.Printf("an error occurred while retrieving tasks of list %q...\n", list.ID)
+		result.Error = err.Error()
+		return result
 	}
 
-	_, _ = w.Write(
-		[]byte(fmt.Sprintf(
-			"stored %v transactions successfully!",
-			count,
-		)),
-	)
+	parsedTasks := parseTasks(tasks, list, catalog)
+
+	if dryRun {
+		result.Transactions = validTransactions(parsedTasks)
+	} else {
+		markInvalidTasks(ctx, graphClient, list.ID, parsedTasks)
+
+		result.Stored, err = storeTransaction(validTransactions(parsedTasks), user)
+		if err != nil {
+			log.Println("an error occurred while storing transactions...")
+			result.Error = err.Error()
+		}
+
+		markStoredTasks(parsedTasks, result.Stored, err)
+		markTasksAsCompleted(ctx, graphClient, list.ID, parsedTasks)
+	}
+
+	for _, p := range parsedTasks {
+		result.Tasks = append(result.Tasks, p.outcome())
+	}
+
+	return result
 }
 
 func storeRefreshedToken() {
@@ -168,249 +229,616 @@ func storeRefreshedToken() {
 	}
 }
 
-func getNotStartedTasks() (*[]models.Task, error) {
-	var tasks taskList
+func getNotStartedTasks(
+	ctx context.Context,
+	graphClient *graph.Client,
+	listID string,
+) (*[]models.Task, int, error) {
+	tasks := make([]models.Task, 0)
+
+	tasksUrl := fmt.Sprintf(
+		FetchTasksUrl,
+		listID,
+		environment.TasksPageSize,
+	)
+
+	pages, more, err := graphClient.GetPages(
+		ctx,
+		tasksUrl,
+		environment.TasksMaxPages,
+		func(value json.RawMessage) error {
+			var page []models.Task
 
-	tasksUrl := fmt.Sprintf(FetchTasksUrl, environment.TaskListID)
+			err := json.Unmarshal(value, &page)
+			tasks = append(tasks, page...)
 
-	log.Printf("listing tasks using url %q...\n", tasksUrl)
-	resp, err := httpClient.Get(tasksUrl)
+			return err
+		},
+	)
 	if err != nil {
-		return nil, err
+		return nil, pages, err
 	}
 
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
+	if more {
 		log.Printf(
-			"unsuccessful request (status code '%v'). retrieving body...\n",
-			resp.StatusCode,
+			"reached the limit of %v pages, remaining tasks will be fetched on the next run\n",
+			environment.TasksMaxPages,
 		)
+	}
 
-		var bodyBytes []byte
+	log.Printf("found %v tasks in %v pages!\n", len(tasks), pages)
 
-		bodyBytes, err = ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
+	return &tasks, pages, nil
+}
+
+// invalidTaskNote puts the reason at the top of the task note, replacing
+// the reason of a previous failure and keeping anything else written there.
+func invalidTaskNote(task *models.Task, reason string) string {
+	note := fmt.Sprintf("%v %v", InvalidTaskSymbol, reason)
 
-		return nil, errors.New(string(bodyBytes))
+	if task.Body.ContentType != "text" {
+		return note
 	}
 
-	log.Println("successful request. parsing body to JSON...")
-	err = json.NewDecoder(resp.Body).Decode(&tasks)
-	if err != nil {
-		return nil, err
+	content := strings.TrimSpace(task.Body.Content)
+	if strings.HasPrefix(content, InvalidTaskSymbol) {
+		parts := strings.SplitN(content, "\n\n", 2)
+		content = ""
+
+		if len(parts) == 2 {
+			content = strings.TrimSpace(parts[1])
+		}
 	}
 
-	log.Printf("found %v tasks!\n", len(tasks.Value))
+	if content == "" {
+		return note
+	}
 
-	return &tasks.Value, nil
+	return note + "\n\n" + content
 }
 
-func markTaskAsInvalid(task *models.Task) error {
-	err := updateTask(task, fmt.Sprintf("{\"title\":\"%v %v\"}", InvalidTaskSymbol, task.Title))
+// isWaitingForFix tells whether the task was flagged as invalid and was not
+// modified since, so parsing it again would fail the same way.
+func isWaitingForFix(task *models.Task) bool {
+	flaggedAt, err := redisClient.GetInvalidTask(task.Id)
 	if err != nil {
-		return err
+		log.Printf("could not check whether task %q is invalid: %v\n", task.Title, err)
+		return false
 	}
 
-	return nil
+	return !flaggedAt.IsZero() && !task.ModifiedAt.After(flaggedAt)
+}
+
+// parsedTask is a task along with the transactions parsed from it, or the
+// reason it could not be parsed. Most tasks make a single transaction, while
+// transfers make one for each account and installments one for each month.
+type parsedTask struct {
+	task         models.Task
+	transactions []entities.Transaction
+	status       string
+	reason       string
 }
 
-func parseTasks(tasks *[]models.Task) (*[]entities.Transaction, []error) {
-	transactions := make([]entities.Transaction, len(*tasks))
-	errorList := make([]error, 0)
+func (p *parsedTask) outcome() models.TaskOutcome {
+	return models.TaskOutcome{
+		TaskID:  p.task.Id,
+		Title:   p.task.Title,
+		Outcome: p.status,
+		Reason:  p.reason,
+	}
+}
+
+// parseTasks parses every task without changing anything, tasks that were
+// flagged as invalid and not modified since are skipped.
+func parseTasks(
+	tasks *[]models.Task,
+	list environment.TaskList,
+	catalog *catalog,
+) []parsedTask {
+	parsedTasks := make([]parsedTask, len(*tasks))
 
 	wg := sync.WaitGroup{}
 
 	for i, task := range *tasks {
-		if strings.HasPrefix(task.Title, InvalidTaskSymbol) {
+		parsedTasks[i].task = task
+
+		if isWaitingForFix(&task) {
+			parsedTasks[i].status = models.TaskSkipped
+			parsedTasks[i].reason = "the task was not modified since it was flagged as invalid"
 			continue
 		}
 
 		wg.Add(1)
-		go func(index int, t models.Task) {
+		go func(p *parsedTask) {
 			defer wg.Done()
-			transactionParts := strings.Split(t.Title, ";")
-
-			if len(transactionParts) != 3 {
-				errorList = append(
-					errorList,
-					errors.New(
-						fmt.Sprintf(
-							"the task: %q is invalid. A transaction must be composed of three parts: one for the cost, one for the description and another for the category",
-							t.Title,
-						),
-					),
-				)
-
-				err := markTaskAsInvalid(&t)
-				if err != nil {
-				}
 
+			transactions, err := parseTask(&p.task, list, catalog)
+			if err != nil {
+				p.status = models.TaskInvalid
+				p.reason = err.Error()
 				return
 			}
 
+			p.transactions = transactions
+			p.status = models.TaskValid
+		}(&parsedTasks[i])
+	}
 
-			cost, err := strconv.ParseFloat(
-				strings.TrimSpace(transactionParts[0]),
-				64,
-			)
+	wg.Wait()
 
-			if err != nil || cost <= 0 {
-				errorList = append(
-					errorList,
-					errors.New(
-						fmt.Sprintf(
-							"cost value in task %q is invalid",
-							t.Title,
-						),
-					),
-				)
-
-				err = markTaskAsInvalid(&t)
-				if err != nil {
-				}
+	return parsedTasks
+}
 
-				return
-			}
+func parseTask(
+	t *models.Task,
+	list environment.TaskList,
+	catalog *catalog,
+) ([]entities.Transaction, error) {
+	title := strings.TrimSpace(strings.TrimPrefix(t.Title, InvalidTaskSymbol))
 
-			description := strings.TrimSpace(transactionParts[1])
-			unparsedCategory := strings.TrimSpace(transactionParts[2])
+	parsed, err := parser.Parse(title)
+	if err != nil {
+		return nil, err
+	}
 
-			if description == "" {
-				errorList = append(
-					errorList,
-					errors.New(
-						fmt.Sprintf(
-							"task %q has no description",
-							t.Title,
-						),
-					),
-				)
+	date := t.CreatedAt
+	if !parsed.Date.IsZero() {
+		date = parsed.Date
+	}
 
-				err = markTaskAsInvalid(&t)
-				if err != nil {
-				}
+	account := list.Account
+	if parsed.Account != "" {
+		account = parsed.Account
+	}
 
-				return
-			}
+	transaction := entities.Transaction{
+		ID:             primitive.NewObjectID(),
+		Date:           date,
+		CreatedAt:      t.CreatedAt,
+		ModifiedAt:     t.ModifiedAt,
+		OriginalTaskID: t.Id,
+		Description:    parsed.Description,
+		Cost:           parsed.Cost,
+		Direction:      entities.DirectionExpense,
+		Account:        account,
+		AccountID:      catalog.accountIDs[account],
+		Tags:           parsed.Tags,
+		Currency:       parsed.Currency,
+	}
 
-			subcategory, err := redisClient.ParseSubcategory(unparsedCategory)
-			if err != nil || subcategory == "" {
-				errorList = append(
-					errorList,
-					errors.New(
-						fmt.Sprintf(
-							"could not parse subcategory of task %q",
-							t.Title,
-						),
-					),
-				)
-
-				err = markTaskAsInvalid(&t)
-				if err != nil {
-				}
+	if parsed.IsTransfer() {
+		return models.SplitTransfer(transaction, parsed.TransferTo, catalog.accountIDs)
+	}
 
-				return
+	subcategory, rule := resolveSubcategory(parsed, catalog.subcategories)
+	if subcategory == "" {
+		return nil, &parser.FieldError{
+			Field:  "category",
+			Value:  parsed.Category,
+			Reason: "could not find a matching subcategory by alias or by the description keywords",
+		}
+	}
+
+	transaction.Category = catalog.categoryOf[subcategory]
+	transaction.Subcategory = subcategory
+	transaction.CategorizedBy = rule
+
+	if parsed.Income {
+		transaction.Direction = entities.DirectionIncome
+	}
+
+	return models.SplitInstallments(transaction, parsed.Installments), nil
+}
+
+// markInvalidTasks writes the reason each invalid task could not be parsed
+// in its note and raises its importance. The tasks are remembered as invalid
+// until someone modifies them again.
+func markInvalidTasks(
+	ctx context.Context,
+	graphClient *graph.Client,
+	listID string,
+	parsedTasks []parsedTask,
+) {
+	var requests []graph.BatchRequest
+
+	for _, p := range parsedTasks {
+		if p.status != models.TaskInvalid {
+			continue
+		}
+
+		log.Printf("the task %q is invalid: %v\n", p.task.Title, p.reason)
+
+		requests = append(
+			requests,
+			graph.NewPatch(
+				p.task.Id,
+				fmt.Sprintf(BatchTaskUrl, listID, p.task.Id),
+				map[string]interface{}{
+					"importance": "high",
+					"body": models.TaskBody{
+						Content:     invalidTaskNote(&p.task, p.reason),
+						ContentType: "text",
+					},
+				},
+			),
+		)
+	}
+
+	if len(requests) == 0 {
+		return
+	}
+
+	responses, err := graphClient.Batch(ctx, requests)
+	if err != nil {
+		log.Printf("could not mark tasks as invalid: %v\n", err)
+	}
+
+	for _, response := range responses {
+		if !response.Succeeded() {
+			log.Printf("could not mark task %q as invalid: %v\n", response.ID, response.Err())
+			continue
+		}
+
+		var updated models.Task
+
+		err = json.Unmarshal(response.Body, &updated)
+		if err == nil {
+			err = redisClient.StoreInvalidTask(response.ID, updated.ModifiedAt)
+		}
+
+		if err != nil {
+			log.Printf("could not flag task %q as invalid: %v\n", response.ID, err)
+		}
+	}
+}
+
+// markStoredTasks tells which of the valid tasks were stored by the store
+// result, so a failure to store some transactions does not keep the tasks
+// of the others from being completed.
+func markStoredTasks(
+	parsedTasks []parsedTask,
+	result models.StoreResult,
+	err error,
+) {
+	stored := make(map[string]bool, len(result.TaskIDs))
+	for _, taskID := range result.TaskIDs {
+		stored[taskID] = true
+	}
+
+	for i := range parsedTasks {
+		p := &parsedTasks[i]
+		if p.status != models.TaskValid {
+			continue
+		}
+
+		p.status = models.TaskStored
+		for _, t := range p.transactions {
+			if !stored[t.OriginalTaskID] {
+				p.status = models.TaskFailedToStore
 			}
+		}
+
+		if p.status == models.TaskStored {
+			continue
+		}
+
+		p.reason = "the transactions were not stored"
+		if err != nil {
+			p.reason = err.Error()
+		}
+	}
+}
+
+func validTransactions(parsedTasks []parsedTask) []entities.Transaction {
+	var transactions []entities.Transaction
+
+	for _, p := range parsedTasks {
+		transactions = append(transactions, p.transactions...)
+	}
+
+	return transactions
+}
+
+// catalog holds the subcategories used to categorize tasks by keyword, the
+// category of each subcategory and the ID of each account by name. Without
+// them tasks can still be categorized by alias, but are stored without a
+// category or account ID.
+type catalog struct {
+	subcategories []entities.Subcategory
+	categoryOf    map[string]string
+	accountIDs    map[string]primitive.ObjectID
+}
 
-			transactions[index] = entities.Transaction{
-				ID:             primitive.NewObjectID(),
-				Date:           t.CreatedAt,
-				CreatedAt:      t.CreatedAt,
-				ModifiedAt:     t.ModifiedAt,
-				OriginalTaskID: t.Id,
-				Description:    description,
-				Cost:           cost,
-				Subcategory:    subcategory,
+func loadCatalog() *catalog {
+	c := &catalog{
+		categoryOf: make(map[string]string),
+		accountIDs: make(map[string]primitive.ObjectID),
+	}
+
+	if accountRepository != nil {
+		accounts, err := accountRepository.GetAllAccounts()
+		if err != nil {
+			log.Printf("could not load accounts: %v\n", err)
+		} else {
+			for _, a := range *accounts {
+				c.accountIDs[a.Name] = a.ID
 			}
+		}
+	}
 
-		}(i, task)
+	if subcategoryRepository == nil || categoryRepository == nil {
+		return c
 	}
 
-	wg.Wait()
+	subcategories, err := subcategoryRepository.GetAllSubcategories()
+	if err != nil {
+		log.Printf("could not load subcategories for keyword matching: %v\n", err)
+		return c
+	}
+
+	c.subcategories = *subcategories
+
+	c.categoryOf, err = categoriesBySubcategory(c.subcategories)
+	if err != nil {
+		log.Printf("could not load categories: %v\n", err)
+		c.categoryOf = make(map[string]string)
+	}
+
+	return c
+}
+
+// resolveSubcategory finds the subcategory of a parsed task, first by the
+// alias typed in the title and then by the keywords in its description. It
+// also returns the rule that matched.
+func resolveSubcategory(
+	parsed *parser.Transaction,
+	subcategories []entities.Subcategory,
+) (string, string) {
+	if parsed.Category != "" {
+		subcategory, err := redisClient.ParseSubcategory(parsed.Category)
+		if err == nil && subcategory != "" {
+			return subcategory, "alias:" + parsed.Category
+		}
+	}
 
-	if len(errorList) > 0 {
-		return &transactions, errorList
+	match := categorizer.Categorize(parsed.Description, subcategories)
+	if match == nil {
+		return "", ""
 	}
 
-	return &transactions, nil
+	log.Printf(
+		"categorized %q as %q by the keywords %q\n",
+		parsed.Description,
+		match.Subcategory,
+		match.Keywords,
+	)
+
+	return match.Subcategory, match.Rule()
 }
 
-func storeTransaction(transactions *[]entities.Transaction) (int, error) {
-	count, err := mongoClient.StoreTransactions(*transactions...)
+// storeTransaction stores the transactions in a single batch, except for
+// transfers, whose sides are stored together one transfer at a time. The
+// transactions stored are announced to the webhooks, even when not all of
+// them could be, and recorded in the audit log as made by the user.
+func storeTransaction(transactions []entities.Transaction, user string) (
+	models.StoreResult,
+	error,
+) {
+	var result models.StoreResult
+	var single []entities.Transaction
+	outs := make(map[primitive.ObjectID]entities.Transaction)
+	ins := make(map[primitive.ObjectID]entities.Transaction)
+
+	for _, t := range transactions {
+		switch {
+		case !t.IsTransfer():
+			single = append(single, t)
+		case t.TransferSide == entities.TransferIn:
+			ins[t.TransferID] = t
+		default:
+			outs[t.TransferID] = t
+		}
+	}
+
+	stored, err := transactionRepository.StoreTransactions(single...)
+	result.Add(stored)
+
+	for transferID, out := range outs {
+		if err != nil {
+			break
+		}
+
+		stored, err = transactionRepository.StoreTransfer(out, ins[transferID])
+		result.Add(stored)
+	}
+
+	audit.Record(
+		auditRepository,
+		entities.AuditSourceToDo,
+		user,
+		audit.StoredChanges(transactions, result)...,
+	)
+	webhooks.PublishStored(webhookRepository, transactions, result)
+
 	if err != nil {
 		log.Println(
 			app_msgs.NotAllTransactionsStored(
-				count,
-				len(*transactions),
+				result.Stored(),
+				len(transactions),
 			),
 		)
-		return count, err
+		return result, err
 	}
 
-	log.Printf(app_msgs.AllTransactionsStored(count))
-	return count, nil
+	log.Printf(
+		app_msgs.TransactionsIngested(
+			len(transactions),
+			result.New,
+			result.AlreadyIngested,
+			result.Updated,
+		),
+	)
+	return result, nil
 }
 
-func updateTask(task *models.Task, payload string) error {
-	urlTask :=
-		fmt.Sprintf(
-			AlterTaskUrl,
-			environment.TaskListID,
-			task.Id,
-		)
-
-	log.Printf("executing request to %q\n", urlTask)
+// markTasksAsCompleted completes the task of every stored transaction. A
+// task that cannot be completed does not stop the others, its outcome
+// changes so the transaction is not left unnoticed.
+func markTasksAsCompleted(
+	ctx context.Context,
+	graphClient *graph.Client,
+	listID string,
+	parsedTasks []parsedTask,
+) {
+	var requests []graph.BatchRequest
+
+	for _, p := range parsedTasks {
+		if p.status == models.TaskStored {
+			requests = append(
+				requests,
+				graph.NewPatch(
+					p.task.Id,
+					fmt.Sprintf(BatchTaskUrl, listID, p.task.Id),
+					map[string]interface{}{"status": "completed"},
+				),
+			)
+		}
+	}
 
-	newReq, err := http.NewRequest(
-		http.MethodPatch,
-		urlTask,
-		strings.NewReader(payload),
-	)
-	if err != nil {
-		return err
+	if len(requests) == 0 {
+		return
 	}
 
-	newReq.Header.Set("Content-Type", "application/json")
-	resp, err := httpClient.Do(newReq)
+	responses, err := graphClient.Batch(ctx, requests)
 	if err != nil {
-		return err
+		log.Println(app_msgs.ErrorCompletingTasks(err.Error()))
 	}
 
-	if resp.StatusCode >= 400 {
-		log.Printf(
-			"unsuccessful request (status code '%v'). retrieving body...\n",
-			resp.StatusCode,
-		)
+	completed := 0
+
+	for i := range parsedTasks {
+		p := &parsedTasks[i]
+		if p.status != models.TaskStored {
+			continue
+		}
 
-		var bodyBytes []byte
+		response, found := responses[p.task.Id]
 
-		bodyBytes, err = ioutil.ReadAll(resp.Body)
+		switch {
+		case !found:
+			p.status = models.TaskFailedToComplete
+			p.reason = "the task was not sent to be completed"
+			continue
+		case !response.Succeeded():
+			log.Println(app_msgs.ErrorCompletingTasks(response.Err().Error()))
+
+			p.status = models.TaskFailedToComplete
+			p.reason = response.Err().Error()
+			continue
+		}
+
+		err = redisClient.ClearInvalidTask(p.task.Id)
 		if err != nil {
-			return err
+			log.Printf("could not clear invalid flag of task %q: %v\n", p.task.Id, err)
 		}
 
-		return errors.New(string(bodyBytes))
+		completed++
 	}
 
-	resp.Body.Close()
-	return nil
+	log.Printf(app_msgs.AllTasksCompleted(completed))
 }
 
-func markTasksAsCompleted(tasks *[]models.Task) error {
-	for _, task := range *tasks {
-		if strings.HasPrefix(task.Title, InvalidTaskSymbol) {
+// raiseBudgetAlerts creates a task in the budget alerts list for each budget
+// of the current month that reached a threshold for the first time. When
+// several thresholds are reached at once, a single task reports the highest.
+// Alerts that cannot be created are raised again on the next ingestion.
+func raiseBudgetAlerts(ctx context.Context, graphClient *graph.Client, now time.Time) {
+	if environment.BudgetAlertListID == "" || budgetRepository == nil {
+		return
+	}
+
+	statuses, err := budgetStatuses(now.Format(models.MonthLayout), now)
+	if err != nil {
+		log.Printf("could not check budgets for alerts: %v\n", err)
+		return
+	}
+
+	for _, status := range statuses {
+		claimed := claimBudgetThresholds(&status)
+		if len(claimed) == 0 {
+			continue
+		}
+
+		err = createBudgetAlert(ctx, graphClient, &status, claimed[len(claimed)-1])
+		if err == nil {
 			continue
 		}
-		
-		err := updateTask(&task, "{\"status\":\"completed\"}")
+
+		log.Printf("could not raise alert of budget %q: %v\n", status.Budget.Name, err)
+
+		for _, threshold := range claimed {
+			err = redisClient.ReleaseBudgetAlert(status.Budget.ID.Hex(), threshold)
+			if err != nil {
+				log.Printf("could not release alert of budget %q: %v\n", status.Budget.Name, err)
+			}
+		}
+	}
+}
+
+// claimBudgetThresholds returns the thresholds the budget reached that were
+// not claimed before, in ascending order.
+func claimBudgetThresholds(status *models.BudgetStatus) []int {
+	var claimed []int
+
+	if status.PercentUsed == nil {
+		return claimed
+	}
+
+	for _, threshold := range environment.BudgetAlertThresholds {
+		if *status.PercentUsed < float64(threshold) {
+			break
+		}
+
+		isNew, err := redisClient.ClaimBudgetAlert(status.Budget.ID.Hex(), threshold)
 		if err != nil {
-			return err
+			log.Printf("could not claim alert of budget %q: %v\n", status.Budget.Name, err)
+			continue
+		}
+
+		if isNew {
+			claimed = append(claimed, threshold)
 		}
 	}
 
-	log.Printf(app_msgs.AllTasksCompleted(len(*tasks)))
-	return nil
+	return claimed
+}
+
+func createBudgetAlert(
+	ctx context.Context,
+	graphClient *graph.Client,
+	status *models.BudgetStatus,
+	threshold int,
+) error {
+	title := app_msgs.BudgetAlertTitle(status.Budget.Name, status.Budget.Month, threshold)
+
+	log.Printf("raising budget alert %q...\n", title)
+
+	return graphClient.Post(
+		ctx,
+		fmt.Sprintf(CreateTaskUrl, environment.BudgetAlertListID),
+		map[string]interface{}{
+			"title":      title,
+			"importance": "high",
+			"body": models.TaskBody{
+				Content: app_msgs.BudgetAlertNote(
+					status.Spent,
+					status.Limit,
+					*status.PercentUsed,
+					status.Projected,
+				),
+				ContentType: "text",
+			},
+		},
+		nil,
+	)
 }
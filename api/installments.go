@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/app_msgs"
+	"github.com/jbonadiman/finances-api/internal/entities"
+	"github.com/jbonadiman/finances-api/internal/models"
+	"github.com/jbonadiman/finances-api/internal/webhooks"
+)
+
+type cancelledInstallments struct {
+	*models.InstallmentPlan
+	Cancelled int `json:"cancelled"`
+}
+
+// ManageInstallments shows (GET) the installments of a purchase, with how
+// many are left to pay, and cancels (DELETE) the ones dated after now. The
+// cancelled installments are soft deleted, so they can still be restored.
+// Both take the group ID shared by the installments in the groupId query
+// parameter.
+func ManageInstallments(w http.ResponseWriter, r *http.Request) {
+	if !authenticate(w, r) {
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		app_msgs.SendMethodNotAllowed(&w, http.MethodGet, http.MethodDelete)
+		return
+	}
+
+	groupID := r.URL.Query().Get("groupId")
+
+	objectID, err := primitive.ObjectIDFromHex(groupID)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidID(groupID))
+		return
+	}
+
+	page, err := transactionRepository.QueryTransactions(
+		models.TransactionQuery{
+			InstallmentGroupID: objectID,
+			Sort:               models.SortByDate,
+		},
+	)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	if page.Total == 0 {
+		app_msgs.SendNotFound(&w, app_msgs.NotFound("installment group", groupID))
+		return
+	}
+
+	now := time.Now().UTC()
+	plan := models.NewInstallmentPlan(objectID, page.Transactions, now)
+
+	if r.Method == http.MethodGet {
+		app_msgs.SendJSON(&w, plan, http.StatusOK)
+		return
+	}
+
+	var kept, before, future []entities.Transaction
+
+	deletedAt := now.Truncate(time.Millisecond)
+	for _, installment := range plan.Installments {
+		if installment.Date.After(now) {
+			before = append(before, installment)
+			installment.DeletedAt = &deletedAt
+			future = append(future, installment)
+		} else {
+			kept = append(kept, installment)
+		}
+	}
+
+	if len(future) > 0 {
+		var ok bool
+
+		future, ok = updateTransactions(w, r, before, future)
+		if !ok {
+			return
+		}
+	}
+
+	log.Printf("cancelled %v installments of group %v\n", len(future), groupID)
+	webhooks.PublishTransactions(webhookRepository, entities.EventTransactionDeleted, future)
+
+	app_msgs.SendJSON(
+		&w,
+		cancelledInstallments{
+			InstallmentPlan: models.NewInstallmentPlan(objectID, kept, now),
+			Cancelled:       len(future),
+		},
+		http.StatusOK,
+	)
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jbonadiman/finances-api/internal/app_msgs"
+	"github.com/jbonadiman/finances-api/internal/databases"
+	"github.com/jbonadiman/finances-api/internal/scheduler"
+)
+
+// MaterializeRecurring stores the due occurrences of every recurring rule.
+// The long running server does it on a schedule, this endpoint lets a cron
+// job do it for serverless deployments. It is safe to call at any time.
+func MaterializeRecurring(w http.ResponseWriter, r *http.Request) {
+	if !authenticate(w, r) {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		app_msgs.SendMethodNotAllowed(&w, http.MethodPost)
+		return
+	}
+
+	if recurringRuleRepository == nil {
+		app_msgs.SendNotImplemented(&w, databases.ErrMongoRequired.Error())
+		return
+	}
+
+	err := scheduler.MaterializeRecurringRules(time.Now().UTC())
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
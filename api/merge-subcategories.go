@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/app_msgs"
+	"github.com/jbonadiman/finances-api/internal/audit"
+	"github.com/jbonadiman/finances-api/internal/databases"
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+type mergeSubcategoriesRequest struct {
+	Sources []string `json:"sources"`
+	Target  string   `json:"target"`
+}
+
+type mergeSubcategoriesResponse struct {
+	Subcategory          *entities.Subcategory `json:"subcategory"`
+	MovedTransactions    int64                 `json:"movedTransactions"`
+	DeletedSubcategories int64                 `json:"deletedSubcategories"`
+}
+
+// MergeSubcategories merges the source subcategories into the target one.
+// The target inherits the names, aliases and keywords of the sources, their
+// transactions, recurring rules and budgets are moved to it and the sources
+// are deleted. Budgets of the same month are merged adding up their limits.
+func MergeSubcategories(w http.ResponseWriter, r *http.Request) {
+	if !authenticate(w, r) {
+		return
+	}
+
+	if subcategoryRepository == nil {
+		app_msgs.SendNotImplemented(&w, databases.ErrMongoRequired.Error())
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		app_msgs.SendMethodNotAllowed(&w, http.MethodPost)
+		return
+	}
+
+	var request mergeSubcategoriesRequest
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody(err.Error()))
+		return
+	}
+
+	if len(request.Sources) == 0 {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("sources are required"))
+		return
+	}
+
+	target, ok := findSubcategory(w, request.Target)
+	if !ok {
+		return
+	}
+
+	var sources []entities.Subcategory
+	var sourceIDs []primitive.ObjectID
+	var sourceNames []string
+
+	for _, id := range request.Sources {
+		source, ok := findSubcategory(w, id)
+		if !ok {
+			return
+		}
+
+		if source.ID == target.ID {
+			app_msgs.SendBadRequest(
+				&w,
+				app_msgs.InvalidBody("a subcategory cannot be merged into itself"),
+			)
+			return
+		}
+
+		sources = append(sources, *source)
+		sourceIDs = append(sourceIDs, source.ID)
+		sourceNames = append(sourceNames, source.Name)
+	}
+
+	for _, source := range sources {
+		target.Aliases = append(target.Aliases, source.Name)
+		target.Aliases = append(target.Aliases, source.Aliases...)
+		target.Keywords = append(target.Keywords, source.Keywords...)
+	}
+
+	target.Aliases = cleanWords(target.Aliases)
+	target.Keywords = cleanWords(target.Keywords)
+
+	transactions, err := subcategoryTransactions(sourceNames)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	moved, err := transactionRepository.RenameSubcategory(sourceNames, target.Name)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	audit.Record(
+		auditRepository,
+		entities.AuditSourceAPI,
+		requestUser(r),
+		audit.Changes(transactions, func(t *entities.Transaction) {
+			t.Subcategory = target.Name
+		})...,
+	)
+
+	err = renameRecurringRules(sourceNames, target.Name)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	err = renameBudgets(sourceNames, target.Name)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	deleted, err := subcategoryRepository.DeleteSubcategories(sourceIDs...)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	err = subcategoryRepository.UpdateSubcategory(target)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	syncSubcategoryCache()
+	updateTransactionCategories(target, requestUser(r))
+
+	log.Printf(
+		"merged %v subcategories into %q, moving %v transactions\n",
+		deleted,
+		target.Name,
+		moved,
+	)
+
+	app_msgs.SendJSON(
+		&w,
+		mergeSubcategoriesResponse{
+			Subcategory:          target,
+			MovedTransactions:    moved,
+			DeletedSubcategories: deleted,
+		},
+		http.StatusOK,
+	)
+}
@@ -2,55 +2,272 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/jbonadiman/finances-api/internal/app_msgs"
-	"github.com/jbonadiman/finances-api/internal/databases/mongodb"
+	"github.com/jbonadiman/finances-api/internal/databases"
 	"github.com/jbonadiman/finances-api/internal/entities"
+	"github.com/jbonadiman/finances-api/internal/models"
+)
+
+const (
+	DateLayout = "2006-01-02"
+
+	MaxPageSize = 500
 )
 
 func init() {
 	var err error
 
-	mongoClient, err = mongodb.GetDB()
+	transactionRepository, err = databases.GetTransactionRepository()
 	if err != nil {
 		log.Println(err.Error())
 	}
 }
 
+// QueryTransactions searches the stored transactions, reporting the income,
+// expenses and net of every match along with the requested page. Every
+// query parameter is optional:
+//
+//	from, to             dates (2021-02-15) or timestamps (RFC 3339), both inclusive
+//	minValue, maxValue   cost range, both inclusive
+//	description          words to search for in the description
+//	subcategory          regular expression matching the subcategory
+//	accountId            ID of the account of the transactions
+//	direction            expense, income or transfer
+//	sort                 date, value, description, subcategory or createdAt
+//	order                asc or desc (default)
+//	page, pageSize       pagination, see below
+//
+// Every match is returned as an array unless page or pageSize is given, in
+// which case the response is an object holding the requested page and the
+// total number of matches.
 func QueryTransactions(w http.ResponseWriter, r *http.Request) {
-	user, password, ok := r.BasicAuth()
-
-	if !ok || !redisClient.CompareAuthentication(user, password) {
-		log.Printf("non-authenticated call with user:password: %q\n",
-			user+":"+password)
-		w.WriteHeader(http.StatusUnauthorized)
-		_, _ = w.Write([]byte("Unauthorized request"))
+	if !authenticate(w, r) {
 		return
 	}
 
-	queryParams := r.URL.Query()
-
-	var transactions *[]entities.Transaction
-	var err error
-
-	if allDataQuery := len(queryParams) == 0; allDataQuery {
-		transactions, err = mongoClient.GetAllTransactions()
-	} else if subcategoryQuery := queryParams.Get("subcategory"); subcategoryQuery != "" {
-		transactions, err = mongoClient.GetTransactionBySubcategory(subcategoryQuery)
+	query, err := parseTransactionQuery(r.URL.Query())
+	if err != nil {
+		app_msgs.SendBadRequest(&w, err.Error())
+		return
 	}
 
+	page, err := transactionRepository.QueryTransactions(*query)
 	if err != nil {
 		app_msgs.SendInternalError(&w, err.Error())
 		return
 	}
 
-	transactionsAsJson, err := json.Marshal(transactions)
+	var response interface{} = page.Transactions
+	if query.PageSize > 0 {
+		response = page
+	}
+
+	transactionsAsJson, err := json.Marshal(response)
 	if err != nil {
 		app_msgs.SendInternalError(&w, err.Error())
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	_, _ = w.Write(transactionsAsJson)
 }
+
+func parseTransactionQuery(params url.Values) (*models.TransactionQuery, error) {
+	var err error
+
+	query := models.TransactionQuery{
+		Description: strings.TrimSpace(params.Get("description")),
+		Subcategory: params.Get("subcategory"),
+		Sort:        models.SortByDate,
+		Descending:  true,
+	}
+
+	if from := params.Get("from"); from != "" {
+		query.From, _, err = parseQueryDate("from", from)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if to := params.Get("to"); to != "" {
+		var isDate bool
+
+		query.To, isDate, err = parseQueryDate("to", to)
+		if err != nil {
+			return nil, err
+		}
+
+		if isDate {
+			query.To = query.To.AddDate(0, 0, 1)
+		} else {
+			query.To = query.To.Add(time.Nanosecond)
+		}
+	}
+
+	if accountID := params.Get("accountId"); accountID != "" {
+		query.AccountID, err = primitive.ObjectIDFromHex(accountID)
+		if err != nil {
+			return nil, errors.New(
+				app_msgs.InvalidQueryParameter("accountId", accountID, "must be an account ID"),
+			)
+		}
+	}
+
+	if direction := params.Get("direction"); direction != "" {
+		if !isDirection(direction) {
+			return nil, errors.New(
+				app_msgs.InvalidQueryParameter(
+					"direction",
+					direction,
+					"must be one of "+strings.Join(entities.Directions, ", "),
+				),
+			)
+		}
+
+		query.Direction = direction
+	}
+
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return nil, errors.New(
+			app_msgs.InvalidQueryParameter("to", params.Get("to"), "must not be before from"),
+		)
+	}
+
+	query.MinValue, err = parseQueryValue(params, "minValue")
+	if err != nil {
+		return nil, err
+	}
+
+	query.MaxValue, err = parseQueryValue(params, "maxValue")
+	if err != nil {
+		return nil, err
+	}
+
+	if query.MinValue != nil && query.MaxValue != nil && *query.MinValue > *query.MaxValue {
+		return nil, errors.New(
+			app_msgs.InvalidQueryParameter("maxValue", params.Get("maxValue"), "must not be less than minValue"),
+		)
+	}
+
+	if sortField := params.Get("sort"); sortField != "" {
+		if !isSortField(sortField) {
+			return nil, errors.New(
+				app_msgs.InvalidQueryParameter(
+					"sort",
+					sortField,
+					"must be one of "+strings.Join(models.SortFields, ", "),
+				),
+			)
+		}
+
+		query.Sort = sortField
+	}
+
+	switch order := params.Get("order"); order {
+	case "", "desc":
+	case "asc":
+		query.Descending = false
+	default:
+		return nil, errors.New(
+			app_msgs.InvalidQueryParameter("order", order, "must be asc or desc"),
+		)
+	}
+
+	page, pageSize := params.Get("page"), params.Get("pageSize")
+	if page != "" || pageSize != "" {
+		query.Page, err = parseQueryInt("page", page, 1, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		query.PageSize, err = parseQueryInt("pageSize", pageSize, 50, MaxPageSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &query, nil
+}
+
+func parseQueryDate(name string, value string) (time.Time, bool, error) {
+	date, err := time.Parse(DateLayout, value)
+	if err == nil {
+		return date, true, nil
+	}
+
+	date, err = time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, errors.New(
+			app_msgs.InvalidQueryParameter(name, value, "must be a date (2021-02-15) or an RFC 3339 timestamp"),
+		)
+	}
+
+	return date, false, nil
+}
+
+func parseQueryValue(params url.Values, name string) (*float64, error) {
+	value := params.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, errors.New(
+			app_msgs.InvalidQueryParameter(name, value, "must be a number"),
+		)
+	}
+
+	return &parsed, nil
+}
+
+func parseQueryInt(name string, value string, defaultValue int, max int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 1 {
+		return 0, errors.New(
+			app_msgs.InvalidQueryParameter(name, value, "must be a positive integer"),
+		)
+	}
+
+	if max > 0 && parsed > max {
+		return 0, errors.New(
+			app_msgs.InvalidQueryParameter(name, value, "must be at most "+strconv.Itoa(max)),
+		)
+	}
+
+	return parsed, nil
+}
+
+func isSortField(field string) bool {
+	for _, f := range models.SortFields {
+		if f == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isDirection(direction string) bool {
+	for _, d := range entities.Directions {
+		if d == direction {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,320 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/app_msgs"
+	"github.com/jbonadiman/finances-api/internal/databases"
+	"github.com/jbonadiman/finances-api/internal/entities"
+	"github.com/jbonadiman/finances-api/internal/recurrence"
+)
+
+var recurringRuleRepository databases.RecurringRuleRepository
+
+type recurringRuleRequest struct {
+	Description *string  `json:"description"`
+	Amount      *float64 `json:"amount"`
+	Direction   *string  `json:"direction"`
+	Subcategory *string  `json:"subcategory"`
+	AccountID   *string  `json:"accountId"`
+	Schedule    *string  `json:"schedule"`
+	Start       *string  `json:"start"`
+	End         *string  `json:"end"`
+}
+
+func init() {
+	var err error
+
+	recurringRuleRepository, err = databases.GetRecurringRuleRepository()
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// ManageRecurringRules lists (GET), creates (POST), updates (PATCH) and
+// deletes (DELETE) the rules of recurring transactions. Updates and
+// deletions take the rule in the id query parameter. Schedules follow the
+// syntax of the recurrence package, and start and end are dates (2021-02-15)
+// or RFC 3339 timestamps. Changes only apply to occurrences not stored yet.
+func ManageRecurringRules(w http.ResponseWriter, r *http.Request) {
+	if !authenticate(w, r) {
+		return
+	}
+
+	if recurringRuleRepository == nil || subcategoryRepository == nil {
+		app_msgs.SendNotImplemented(&w, databases.ErrMongoRequired.Error())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		listRecurringRules(w)
+	case http.MethodPost:
+		createRecurringRule(w, r)
+	case http.MethodPatch:
+		updateRecurringRule(w, r)
+	case http.MethodDelete:
+		deleteRecurringRule(w, r)
+	default:
+		app_msgs.SendMethodNotAllowed(
+			&w,
+			http.MethodGet,
+			http.MethodPost,
+			http.MethodPatch,
+			http.MethodDelete,
+		)
+	}
+}
+
+func listRecurringRules(w http.ResponseWriter) {
+	rules, err := recurringRuleRepository.GetAllRecurringRules()
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	app_msgs.SendJSON(&w, rules, http.StatusOK)
+}
+
+func createRecurringRule(w http.ResponseWriter, r *http.Request) {
+	var request recurringRuleRequest
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody(err.Error()))
+		return
+	}
+
+	if request.Description == nil || request.Amount == nil ||
+		request.Subcategory == nil || request.Schedule == nil {
+		app_msgs.SendBadRequest(
+			&w,
+			app_msgs.InvalidBody("description, amount, subcategory and schedule are required"),
+		)
+		return
+	}
+
+	now := time.Now().UTC()
+	rule := entities.RecurringRule{
+		ID:        primitive.NewObjectID(),
+		Direction: entities.DirectionExpense,
+		Start:     now,
+	}
+
+	if !applyRecurringRuleRequest(w, &rule, &request) {
+		return
+	}
+
+	rule.ModifiedAt = now
+
+	err = recurringRuleRepository.StoreRecurringRule(&rule)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	app_msgs.SendJSON(&w, rule, http.StatusCreated)
+}
+
+func updateRecurringRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := findRecurringRule(w, r.URL.Query().Get("id"))
+	if !ok {
+		return
+	}
+
+	var request recurringRuleRequest
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody(err.Error()))
+		return
+	}
+
+	if !applyRecurringRuleRequest(w, rule, &request) {
+		return
+	}
+
+	rule.ModifiedAt = time.Now().UTC()
+
+	err = recurringRuleRepository.UpdateRecurringRule(rule)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	app_msgs.SendJSON(&w, rule, http.StatusOK)
+}
+
+func deleteRecurringRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := findRecurringRule(w, r.URL.Query().Get("id"))
+	if !ok {
+		return
+	}
+
+	err := recurringRuleRepository.DeleteRecurringRule(rule.ID)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyRecurringRuleRequest validates the fields present in the request and
+// sets them on the rule.
+func applyRecurringRuleRequest(
+	w http.ResponseWriter,
+	rule *entities.RecurringRule,
+	request *recurringRuleRequest,
+) bool {
+	if request.Description != nil {
+		description := strings.TrimSpace(*request.Description)
+		if description == "" {
+			app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("description must not be empty"))
+			return false
+		}
+
+		rule.Description = description
+	}
+
+	if request.Amount != nil {
+		if *request.Amount <= 0 {
+			app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("amount must be greater than zero"))
+			return false
+		}
+
+		rule.Amount = *request.Amount
+	}
+
+	if request.Direction != nil {
+		direction := *request.Direction
+		if direction != entities.DirectionExpense && direction != entities.DirectionIncome {
+			app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("direction must be expense or income"))
+			return false
+		}
+
+		rule.Direction = direction
+	}
+
+	if request.Subcategory != nil {
+		if !subcategoryExists(w, *request.Subcategory) {
+			return false
+		}
+
+		rule.Subcategory = *request.Subcategory
+	}
+
+	if request.AccountID != nil && !applyRuleAccount(w, rule, *request.AccountID) {
+		return false
+	}
+
+	if request.Schedule != nil {
+		_, err := recurrence.Parse(*request.Schedule)
+		if err != nil {
+			app_msgs.SendBadRequest(&w, app_msgs.InvalidBody(err.Error()))
+			return false
+		}
+
+		rule.Schedule = *request.Schedule
+	}
+
+	if request.Start != nil {
+		start, _, err := parseQueryDate("start", *request.Start)
+		if err != nil {
+			app_msgs.SendBadRequest(&w, app_msgs.InvalidBody(err.Error()))
+			return false
+		}
+
+		rule.Start = start
+	}
+
+	if request.End != nil {
+		rule.End = nil
+
+		if *request.End != "" {
+			end, _, err := parseQueryDate("end", *request.End)
+			if err != nil {
+				app_msgs.SendBadRequest(&w, app_msgs.InvalidBody(err.Error()))
+				return false
+			}
+
+			rule.End = &end
+		}
+	}
+
+	if rule.End != nil && rule.End.Before(rule.Start) {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("end must not be before start"))
+		return false
+	}
+
+	return true
+}
+
+func subcategoryExists(w http.ResponseWriter, name string) bool {
+	subcategories, err := subcategoryRepository.GetAllSubcategories()
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return false
+	}
+
+	for _, s := range *subcategories {
+		if s.Name == name {
+			return true
+		}
+	}
+
+	app_msgs.SendBadRequest(
+		&w,
+		app_msgs.InvalidBody("there is no subcategory named "+name),
+	)
+	return false
+}
+
+// applyRuleAccount sets the account of the rule, an empty ID removes it.
+func applyRuleAccount(w http.ResponseWriter, rule *entities.RecurringRule, id string) bool {
+	if id == "" {
+		rule.Account, rule.AccountID = "", primitive.NilObjectID
+		return true
+	}
+
+	if accountRepository == nil {
+		app_msgs.SendNotImplemented(&w, databases.ErrMongoRequired.Error())
+		return false
+	}
+
+	account, ok := findAccount(w, id)
+	if !ok {
+		return false
+	}
+
+	rule.Account, rule.AccountID = account.Name, account.ID
+	return true
+}
+
+func findRecurringRule(w http.ResponseWriter, id string) (*entities.RecurringRule, bool) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidID(id))
+		return nil, false
+	}
+
+	rule, err := recurringRuleRepository.GetRecurringRule(objectID)
+	if errors.Is(err, databases.ErrNotFound) {
+		app_msgs.SendNotFound(&w, app_msgs.NotFound("recurring rule", id))
+		return nil, false
+	}
+
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return nil, false
+	}
+
+	return rule, true
+}
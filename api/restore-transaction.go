@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/jbonadiman/finances-api/internal/app_msgs"
+	"github.com/jbonadiman/finances-api/internal/entities"
+	"github.com/jbonadiman/finances-api/internal/webhooks"
+)
+
+// RestoreTransaction brings back the deleted transaction in the id query
+// parameter, along with the other side of its transfer. Like deletions, it
+// accepts the modifiedAt of the transaction as it was read in the modifiedAt
+// query parameter. Restored transactions are announced as created.
+func RestoreTransaction(w http.ResponseWriter, r *http.Request) {
+	if !authenticate(w, r) {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		app_msgs.SendMethodNotAllowed(&w, http.MethodPost)
+		return
+	}
+
+	transaction, ok := findTransaction(w, r.URL.Query().Get("id"))
+	if !ok {
+		return
+	}
+
+	if !transaction.IsDeleted() {
+		app_msgs.SendJSON(&w, transaction, http.StatusOK)
+		return
+	}
+
+	if !matchesModifiedAt(w, r, transaction) {
+		return
+	}
+
+	sides, ok := transferSidesOf(w, transaction)
+	if !ok {
+		return
+	}
+
+	before := append([]entities.Transaction(nil), sides...)
+
+	for i := range sides {
+		sides[i].DeletedAt = nil
+	}
+
+	restored, ok := updateTransactions(w, r, before, sides)
+	if !ok {
+		return
+	}
+
+	webhooks.PublishTransactions(webhookRepository, entities.EventTransactionCreated, restored)
+
+	app_msgs.SendJSON(&w, restored[0], http.StatusOK)
+}
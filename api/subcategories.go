@@ -0,0 +1,631 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/app_msgs"
+	"github.com/jbonadiman/finances-api/internal/audit"
+	"github.com/jbonadiman/finances-api/internal/databases"
+	"github.com/jbonadiman/finances-api/internal/entities"
+	"github.com/jbonadiman/finances-api/internal/models"
+)
+
+var subcategoryRepository databases.SubcategoryRepository
+
+type subcategoryRequest struct {
+	Name           *string   `json:"name"`
+	Aliases        *[]string `json:"aliases"`
+	Keywords       *[]string `json:"keywords"`
+	AddKeywords    []string  `json:"addKeywords"`
+	RemoveKeywords []string  `json:"removeKeywords"`
+	CategoryID     *string   `json:"categoryId"`
+}
+
+func init() {
+	var err error
+
+	subcategoryRepository, err = databases.GetSubcategoryRepository()
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// ManageSubcategories lists (GET), creates (POST), updates (PATCH) and
+// deletes (DELETE) subcategories. Updates and deletions take the subcategory
+// in the id query parameter. Updating accepts a new name, the full list of
+// aliases or keywords, or keywords to add and remove. Every change is
+// mirrored to the alias cache used when parsing tasks.
+func ManageSubcategories(w http.ResponseWriter, r *http.Request) {
+	if !authenticate(w, r) {
+		return
+	}
+
+	if subcategoryRepository == nil {
+		app_msgs.SendNotImplemented(&w, databases.ErrMongoRequired.Error())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		listSubcategories(w)
+	case http.MethodPost:
+		createSubcategory(w, r)
+	case http.MethodPatch:
+		updateSubcategory(w, r)
+	case http.MethodDelete:
+		deleteSubcategory(w, r)
+	default:
+		app_msgs.SendMethodNotAllowed(
+			&w,
+			http.MethodGet,
+			http.MethodPost,
+			http.MethodPatch,
+			http.MethodDelete,
+		)
+	}
+}
+
+func listSubcategories(w http.ResponseWriter) {
+	subcategories, err := subcategoryRepository.GetAllSubcategories()
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	app_msgs.SendJSON(&w, subcategories, http.StatusOK)
+}
+
+func createSubcategory(w http.ResponseWriter, r *http.Request) {
+	var request subcategoryRequest
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody(err.Error()))
+		return
+	}
+
+	if request.Name == nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("name is required"))
+		return
+	}
+
+	subcategory := entities.Subcategory{
+		ID:       primitive.NewObjectID(),
+		Keywords: make([]string, 0),
+		Aliases:  make([]string, 0),
+	}
+
+	err = request.applyTo(&subcategory)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody(err.Error()))
+		return
+	}
+
+	if !applyCategory(w, request.CategoryID, &subcategory) {
+		return
+	}
+
+	err = checkSubcategoryConflicts(&subcategory)
+	if err != nil {
+		app_msgs.SendConflict(&w, err.Error())
+		return
+	}
+
+	err = subcategoryRepository.StoreSubcategory(&subcategory)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	syncSubcategoryCache()
+	updateTransactionCategories(&subcategory, requestUser(r))
+
+	app_msgs.SendJSON(&w, subcategory, http.StatusCreated)
+}
+
+func updateSubcategory(w http.ResponseWriter, r *http.Request) {
+	subcategory, ok := findSubcategory(w, r.URL.Query().Get("id"))
+	if !ok {
+		return
+	}
+
+	var request subcategoryRequest
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody(err.Error()))
+		return
+	}
+
+	oldName := subcategory.Name
+	oldCategoryID := subcategory.CategoryID
+
+	err = request.applyTo(subcategory)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody(err.Error()))
+		return
+	}
+
+	if !applyCategory(w, request.CategoryID, subcategory) {
+		return
+	}
+
+	err = checkSubcategoryConflicts(subcategory)
+	if err != nil {
+		app_msgs.SendConflict(&w, err.Error())
+		return
+	}
+
+	err = subcategoryRepository.UpdateSubcategory(subcategory)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	if subcategory.Name != oldName {
+		transactions, err := subcategoryTransactions([]string{oldName})
+		if err != nil {
+			app_msgs.SendInternalError(&w, err.Error())
+			return
+		}
+
+		renamed, err := transactionRepository.RenameSubcategory(
+			[]string{oldName},
+			subcategory.Name,
+		)
+		if err != nil {
+			app_msgs.SendInternalError(&w, err.Error())
+			return
+		}
+
+		audit.Record(
+			auditRepository,
+			entities.AuditSourceAPI,
+			requestUser(r),
+			audit.Changes(transactions, func(t *entities.Transaction) {
+				t.Subcategory = subcategory.Name
+			})...,
+		)
+
+		log.Printf(
+			"renamed subcategory %q to %q in %v transactions\n",
+			oldName,
+			subcategory.Name,
+			renamed,
+		)
+
+		err = renameRecurringRules([]string{oldName}, subcategory.Name)
+		if err != nil {
+			app_msgs.SendInternalError(&w, err.Error())
+			return
+		}
+
+		err = renameBudgets([]string{oldName}, subcategory.Name)
+		if err != nil {
+			app_msgs.SendInternalError(&w, err.Error())
+			return
+		}
+	}
+
+	syncSubcategoryCache()
+
+	if subcategory.CategoryID != oldCategoryID {
+		updateTransactionCategories(subcategory, requestUser(r))
+	}
+
+	app_msgs.SendJSON(&w, subcategory, http.StatusOK)
+}
+
+func deleteSubcategory(w http.ResponseWriter, r *http.Request) {
+	subcategory, ok := findSubcategory(w, r.URL.Query().Get("id"))
+	if !ok {
+		return
+	}
+
+	page, err := transactionRepository.QueryTransactions(
+		models.TransactionQuery{
+			Subcategory: "^" + regexp.QuoteMeta(subcategory.Name) + "$",
+			PageSize:    1,
+		},
+	)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	if page.Total > 0 {
+		app_msgs.SendConflict(
+			&w,
+			fmt.Sprintf(
+				"error: subcategory %q still has %v transactions, merge it into another subcategory instead",
+				subcategory.Name,
+				page.Total,
+			),
+		)
+		return
+	}
+
+	_, err = subcategoryRepository.DeleteSubcategories(subcategory.ID)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	syncSubcategoryCache()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func findSubcategory(w http.ResponseWriter, id string) (
+	*entities.Subcategory,
+	bool,
+) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidID(id))
+		return nil, false
+	}
+
+	subcategory, err := subcategoryRepository.GetSubcategory(objectID)
+	if errors.Is(err, databases.ErrNotFound) {
+		app_msgs.SendNotFound(&w, app_msgs.NotFound("subcategory", id))
+		return nil, false
+	}
+
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return nil, false
+	}
+
+	return subcategory, true
+}
+
+// checkSubcategoryConflicts makes sure no other subcategory already uses
+// the name or one of the aliases of the given subcategory.
+func checkSubcategoryConflicts(subcategory *entities.Subcategory) error {
+	subcategories, err := subcategoryRepository.GetAllSubcategories()
+	if err != nil {
+		return err
+	}
+
+	used := make(map[string]string)
+	for _, s := range *subcategories {
+		if s.ID == subcategory.ID {
+			continue
+		}
+
+		used[s.Name] = s.Name
+		for _, alias := range s.Aliases {
+			used[alias] = s.Name
+		}
+	}
+
+	for _, name := range append([]string{subcategory.Name}, subcategory.Aliases...) {
+		if owner, found := used[name]; found {
+			return fmt.Errorf(
+				"error: %q is already used by the subcategory %q",
+				name,
+				owner,
+			)
+		}
+	}
+
+	return nil
+}
+
+// applyCategory sets the category requested for the subcategory, an empty
+// id removing it from its category.
+func applyCategory(
+	w http.ResponseWriter,
+	categoryID *string,
+	subcategory *entities.Subcategory,
+) bool {
+	if categoryID == nil {
+		return true
+	}
+
+	if *categoryID == "" {
+		subcategory.CategoryID = primitive.NilObjectID
+		return true
+	}
+
+	if categoryRepository == nil {
+		app_msgs.SendNotImplemented(&w, databases.ErrMongoRequired.Error())
+		return false
+	}
+
+	category, ok := findCategory(w, *categoryID)
+	if !ok {
+		return false
+	}
+
+	subcategory.CategoryID = category.ID
+	return true
+}
+
+// updateTransactionCategories sets the category of the transactions of the
+// subcategory to its current category, on behalf of the user.
+func updateTransactionCategories(subcategory *entities.Subcategory, user string) {
+	var category string
+
+	if !subcategory.CategoryID.IsZero() {
+		c, err := categoryRepository.GetCategory(subcategory.CategoryID)
+		if err != nil {
+			log.Printf("could not find the category of %q: %v\n", subcategory.Name, err)
+			return
+		}
+
+		category = c.Name
+	}
+
+	transactions, err := subcategoryTransactions([]string{subcategory.Name})
+	if err != nil {
+		log.Printf("could not update the category of transactions: %v\n", err)
+		return
+	}
+
+	changed, err := transactionRepository.SetCategory(
+		[]string{subcategory.Name},
+		category,
+	)
+	if err != nil {
+		log.Printf("could not update the category of transactions: %v\n", err)
+		return
+	}
+
+	audit.Record(
+		auditRepository,
+		entities.AuditSourceAPI,
+		user,
+		audit.Changes(transactions, func(t *entities.Transaction) {
+			t.Category = category
+		})...,
+	)
+
+	log.Printf(
+		"set category %q on %v transactions of %q\n",
+		category,
+		changed,
+		subcategory.Name,
+	)
+}
+
+// renameRecurringRules moves the recurring rules of the old subcategories
+// to the new one, so their next occurrences are stored under it.
+func renameRecurringRules(oldNames []string, newName string) error {
+	if recurringRuleRepository == nil {
+		return nil
+	}
+
+	rules, err := recurringRuleRepository.GetAllRecurringRules()
+	if err != nil {
+		return err
+	}
+
+	renamed := models.RenameRecurringRules(*rules, oldNames, newName, time.Now().UTC())
+	for i := range renamed {
+		err = recurringRuleRepository.UpdateRecurringRule(&renamed[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Printf("moved %v recurring rules to the subcategory %q\n", len(renamed), newName)
+
+	return nil
+}
+
+// renameBudgets moves the budgets of the old subcategories to the new one,
+// merging the budgets of a month that end up under it.
+func renameBudgets(oldNames []string, newName string) error {
+	if budgetRepository == nil {
+		return nil
+	}
+
+	budgets, err := budgetRepository.GetBudgets()
+	if err != nil {
+		return err
+	}
+
+	renamed, removed := models.RenameBudgets(*budgets, oldNames, newName)
+	for _, id := range removed {
+		err = budgetRepository.DeleteBudget(id)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range renamed {
+		err = budgetRepository.SetBudget(&renamed[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Printf("moved %v budgets to the subcategory %q\n", len(renamed), newName)
+
+	return nil
+}
+
+// syncSubcategoryCache writes the aliases of the stored subcategories to
+// the cache. The aliases that were only cached are imported the first time,
+// and until they are, no alias is removed from the cache.
+func syncSubcategoryCache() {
+	imported, err := importSubcategoryAliases()
+	if err != nil {
+		log.Printf("could not import the cached subcategory aliases: %v\n", err)
+	}
+
+	subcategories, err := subcategoryRepository.GetAllSubcategories()
+	if err == nil {
+		err = redisClient.SyncSubcategories(*subcategories, imported)
+	}
+
+	if err != nil {
+		log.Printf("could not sync the subcategory cache: %v\n", err)
+	}
+}
+
+// importSubcategoryAliases stores the cached aliases missing from the
+// subcategories, creating the subcategories that were only cached, and
+// returns whether the import is done. Aliases already used by another
+// subcategory are left to it.
+func importSubcategoryAliases() (bool, error) {
+	imported, err := redisClient.SubcategoriesImported()
+	if err != nil || imported {
+		return imported, err
+	}
+
+	cached, err := redisClient.GetSubcategoryAliases()
+	if err != nil {
+		return false, err
+	}
+
+	subcategories, err := subcategoryRepository.GetAllSubcategories()
+	if err != nil {
+		return false, err
+	}
+
+	byName := make(map[string]*entities.Subcategory)
+	used := make(map[string]string)
+	for i := range *subcategories {
+		s := &(*subcategories)[i]
+
+		byName[s.Name] = s
+		used[s.Name] = s.Name
+		for _, alias := range s.Aliases {
+			used[alias] = s.Name
+		}
+	}
+
+	aliases := make([]string, 0, len(cached))
+	for alias := range cached {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	changed := make(map[string]bool)
+	var created []*entities.Subcategory
+
+	for _, alias := range aliases {
+		name := cached[alias]
+
+		subcategory, found := byName[name]
+		if !found {
+			if _, taken := used[name]; taken {
+				continue
+			}
+
+			subcategory = &entities.Subcategory{
+				ID:       primitive.NewObjectID(),
+				Name:     name,
+				Keywords: make([]string, 0),
+				Aliases:  make([]string, 0),
+			}
+
+			byName[name] = subcategory
+			used[name] = name
+			created = append(created, subcategory)
+		}
+
+		if _, taken := used[alias]; taken {
+			continue
+		}
+
+		subcategory.Aliases = append(subcategory.Aliases, alias)
+		used[alias] = name
+		changed[name] = true
+	}
+
+	for _, subcategory := range created {
+		err = subcategoryRepository.StoreSubcategory(subcategory)
+		if err != nil {
+			return false, err
+		}
+
+		delete(changed, subcategory.Name)
+	}
+
+	for name := range changed {
+		err = subcategoryRepository.UpdateSubcategory(byName[name])
+		if err != nil {
+			return false, err
+		}
+	}
+
+	log.Printf(
+		"imported %v cached subcategory aliases, creating %v subcategories\n",
+		len(cached),
+		len(created),
+	)
+
+	return true, redisClient.MarkSubcategoriesImported()
+}
+
+func (request *subcategoryRequest) applyTo(
+	subcategory *entities.Subcategory,
+) error {
+	if request.Name != nil {
+		name := strings.TrimSpace(*request.Name)
+		if name == "" {
+			return errors.New("name must not be empty")
+		}
+
+		subcategory.Name = name
+	}
+
+	if request.Aliases != nil {
+		subcategory.Aliases = cleanWords(*request.Aliases)
+	}
+
+	if request.Keywords != nil {
+		subcategory.Keywords = cleanWords(*request.Keywords)
+	}
+
+	subcategory.Keywords = cleanWords(
+		append(subcategory.Keywords, request.AddKeywords...),
+	)
+
+	removed := make(map[string]bool)
+	for _, keyword := range cleanWords(request.RemoveKeywords) {
+		removed[keyword] = true
+	}
+
+	keywords := make([]string, 0, len(subcategory.Keywords))
+	for _, keyword := range subcategory.Keywords {
+		if !removed[keyword] {
+			keywords = append(keywords, keyword)
+		}
+	}
+
+	subcategory.Keywords = keywords
+	return nil
+}
+
+// cleanWords trims the given words, dropping empty and repeated ones.
+func cleanWords(words []string) []string {
+	cleaned := make([]string, 0, len(words))
+	seen := make(map[string]bool)
+
+	for _, word := range words {
+		word = strings.TrimSpace(word)
+		if word == "" || seen[word] {
+			continue
+		}
+
+		seen[word] = true
+		cleaned = append(cleaned, word)
+	}
+
+	return cleaned
+}
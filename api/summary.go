@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jbonadiman/finances-api/internal/app_msgs"
+	"github.com/jbonadiman/finances-api/internal/models"
+)
+
+type summaryResponse struct {
+	*models.Summary
+	Comparison *models.SummaryComparison `json:"comparison,omitempty"`
+}
+
+// SummarizeTransactions reports the expenses, income and net of the
+// transactions grouped by month, subcategory and category. The total, count
+// and average of each group only take expenses into account, and transfers
+// are left out. The optional from and to query parameters accept the same
+// formats as QueryTransactions. When from is given, the summary is also
+// compared against the period of the same length right before it.
+func SummarizeTransactions(w http.ResponseWriter, r *http.Request) {
+	if !authenticate(w, r) {
+		return
+	}
+
+	from, to, err := parseSummaryPeriod(r)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, err.Error())
+		return
+	}
+
+	summary, err := transactionRepository.SummarizeTransactions(from, to)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	summary.Round()
+	response := summaryResponse{Summary: summary}
+
+	if !from.IsZero() {
+		summary.From, summary.To = &from, &to
+
+		previousFrom := from.Add(-to.Sub(from))
+
+		previous, err := transactionRepository.SummarizeTransactions(previousFrom, from)
+		if err != nil {
+			app_msgs.SendInternalError(&w, err.Error())
+			return
+		}
+
+		previous.Round()
+		previous.From, previous.To = &previousFrom, &from
+
+		response.Comparison = summary.Compare(previous)
+	}
+
+	summaryAsJson, err := json.Marshal(response)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(summaryAsJson)
+}
+
+func parseSummaryPeriod(r *http.Request) (time.Time, time.Time, error) {
+	query, err := parseTransactionQuery(r.URL.Query())
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	if !query.From.IsZero() && query.To.IsZero() {
+		query.To = time.Now().UTC()
+
+		if !query.From.Before(query.To) {
+			return time.Time{}, time.Time{}, errors.New(
+				app_msgs.InvalidQueryParameter("from", r.URL.Query().Get("from"), "must be in the past"),
+			)
+		}
+	}
+
+	return query.From, query.To, nil
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/app_msgs"
+	"github.com/jbonadiman/finances-api/internal/databases"
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+var auditRepository databases.AuditRepository
+
+func init() {
+	var err error
+
+	auditRepository, err = databases.GetAuditRepository()
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// GetTransactionHistory lists the audit log of the transaction in the id
+// query parameter, oldest first. Each entry tells what was done (insert,
+// update, recategorize, delete or restore), who did it, when, from where
+// (todo, api, recurring or import) and the fields it changed, before and
+// after. The history outlives the transaction, so it is still available
+// after the transaction is removed for good.
+func GetTransactionHistory(w http.ResponseWriter, r *http.Request) {
+	if !authenticate(w, r) {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		app_msgs.SendMethodNotAllowed(&w, http.MethodGet)
+		return
+	}
+
+	if auditRepository == nil {
+		app_msgs.SendNotImplemented(&w, databases.ErrMongoRequired.Error())
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidID(id))
+		return
+	}
+
+	history, err := auditRepository.GetTransactionHistory(objectID)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	app_msgs.SendJSON(&w, history, http.StatusOK)
+}
+
+// subcategoryTransactions returns the transactions of the subcategories as
+// they are before changing them, so the change can be audited.
+func subcategoryTransactions(names []string) ([]entities.Transaction, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = regexp.QuoteMeta(name)
+	}
+
+	transactions, err := transactionRepository.GetTransactionBySubcategory(
+		"^(?:" + strings.Join(quoted, "|") + ")$",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return *transactions, nil
+}
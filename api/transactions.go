@@ -0,0 +1,454 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/app_msgs"
+	"github.com/jbonadiman/finances-api/internal/audit"
+	"github.com/jbonadiman/finances-api/internal/databases"
+	"github.com/jbonadiman/finances-api/internal/entities"
+	"github.com/jbonadiman/finances-api/internal/models"
+	"github.com/jbonadiman/finances-api/internal/parser"
+	"github.com/jbonadiman/finances-api/internal/webhooks"
+)
+
+// ManualCategorization is the rule recorded when a subcategory is set
+// through the API by its name.
+const ManualCategorization = "manual"
+
+type transactionPatch struct {
+	ModifiedAt  *time.Time `json:"modifiedAt"`
+	Description *string    `json:"description"`
+	Value       *float64   `json:"value"`
+	Direction   *string    `json:"direction"`
+	Subcategory *string    `json:"subcategory"`
+	Account     *string    `json:"account"`
+	Date        *string    `json:"date"`
+	Tags        *[]string  `json:"tags"`
+	Currency    *string    `json:"currency"`
+}
+
+// ManageTransaction shows (GET), changes (PATCH) and deletes (DELETE) the
+// transaction in the id query parameter. Changes follow the same rules as the
+// titles of ingested tasks, and the subcategory may be given by name or by
+// alias.
+//
+// To keep changes from overwriting each other, PATCH requires the modifiedAt
+// of the transaction as it was read, and DELETE accepts it in the
+// modifiedAt query parameter. When it no longer matches, the transaction was
+// changed since and nothing is done. Deletions are soft, deleted
+// transactions are left out of everything until RestoreTransaction brings
+// them back. Both sides of a transfer are changed and deleted together.
+func ManageTransaction(w http.ResponseWriter, r *http.Request) {
+	if !authenticate(w, r) {
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPatch &&
+		r.Method != http.MethodDelete {
+		app_msgs.SendMethodNotAllowed(&w, http.MethodGet, http.MethodPatch, http.MethodDelete)
+		return
+	}
+
+	transaction, ok := findTransaction(w, r.URL.Query().Get("id"))
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		app_msgs.SendJSON(&w, transaction, http.StatusOK)
+	case http.MethodPatch:
+		patchTransaction(w, r, transaction)
+	case http.MethodDelete:
+		deleteTransaction(w, r, transaction)
+	}
+}
+
+func patchTransaction(w http.ResponseWriter, r *http.Request, transaction *entities.Transaction) {
+	var patch transactionPatch
+
+	err := json.NewDecoder(r.Body).Decode(&patch)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody(err.Error()))
+		return
+	}
+
+	if patch.ModifiedAt == nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("modifiedAt is required"))
+		return
+	}
+
+	if transaction.IsDeleted() {
+		app_msgs.SendConflict(&w, app_msgs.TransactionDeleted(transaction.ID.Hex()))
+		return
+	}
+
+	if !transaction.ModifiedAt.Equal(*patch.ModifiedAt) {
+		sendTransactionModified(w, transaction)
+		return
+	}
+
+	sides, ok := transferSidesOf(w, transaction)
+	if !ok {
+		return
+	}
+
+	before := append([]entities.Transaction(nil), sides...)
+	catalog := loadCatalog()
+
+	for i := range sides {
+		if !applyTransactionPatch(w, &sides[i], &patch, catalog, i == 0) {
+			return
+		}
+	}
+
+	if len(sides) > 1 && sides[0].Account == sides[1].Account {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("a transfer must be between different accounts"))
+		return
+	}
+
+	updated, ok := updateTransactions(w, r, before, sides)
+	if !ok {
+		return
+	}
+
+	webhooks.PublishTransactions(webhookRepository, entities.EventTransactionUpdated, updated)
+
+	app_msgs.SendJSON(&w, updated[0], http.StatusOK)
+}
+
+func deleteTransaction(w http.ResponseWriter, r *http.Request, transaction *entities.Transaction) {
+	if transaction.IsDeleted() {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if !matchesModifiedAt(w, r, transaction) {
+		return
+	}
+
+	sides, ok := transferSidesOf(w, transaction)
+	if !ok {
+		return
+	}
+
+	before := append([]entities.Transaction(nil), sides...)
+
+	deletedAt := time.Now().UTC().Truncate(time.Millisecond)
+	for i := range sides {
+		sides[i].DeletedAt = &deletedAt
+	}
+
+	deleted, ok := updateTransactions(w, r, before, sides)
+	if !ok {
+		return
+	}
+
+	webhooks.PublishTransactions(webhookRepository, entities.EventTransactionDeleted, deleted)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyTransactionPatch validates the fields present in the patch and sets
+// them on the transaction. The account is only set on the side being
+// patched, the other side of a transfer keeps its own.
+func applyTransactionPatch(
+	w http.ResponseWriter,
+	t *entities.Transaction,
+	patch *transactionPatch,
+	catalog *catalog,
+	patched bool,
+) bool {
+	if patch.Description != nil {
+		description := strings.TrimSpace(*patch.Description)
+		if description == "" {
+			app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("description must not be empty"))
+			return false
+		}
+
+		t.Description = description
+	}
+
+	if patch.Value != nil {
+		if *patch.Value <= 0 {
+			app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("value must be greater than zero"))
+			return false
+		}
+
+		t.Cost = *patch.Value
+	}
+
+	if patch.Direction != nil {
+		if t.IsTransfer() {
+			app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("the direction of a transfer cannot be changed"))
+			return false
+		}
+
+		direction := *patch.Direction
+		if direction != entities.DirectionExpense && direction != entities.DirectionIncome {
+			app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("direction must be expense or income"))
+			return false
+		}
+
+		t.Direction = direction
+	}
+
+	if patch.Subcategory != nil && !applyTransactionSubcategory(w, t, *patch.Subcategory, catalog) {
+		return false
+	}
+
+	if patch.Account != nil && patched && !applyTransactionAccount(w, t, *patch.Account, catalog) {
+		return false
+	}
+
+	if patch.Date != nil {
+		date, _, err := parseQueryDate("date", *patch.Date)
+		if err != nil {
+			app_msgs.SendBadRequest(&w, app_msgs.InvalidBody(err.Error()))
+			return false
+		}
+
+		t.Date = date
+	}
+
+	if patch.Tags != nil {
+		tags, ok := transactionTags(w, *patch.Tags)
+		if !ok {
+			return false
+		}
+
+		t.Tags = tags
+	}
+
+	if patch.Currency != nil {
+		if *patch.Currency != "" && !parser.IsCurrency(*patch.Currency) {
+			app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("currency must be a three letter code, as in USD"))
+			return false
+		}
+
+		t.Currency = *patch.Currency
+	}
+
+	return true
+}
+
+// applyTransactionSubcategory sets the subcategory, given by name or by
+// alias, and its category.
+func applyTransactionSubcategory(
+	w http.ResponseWriter,
+	t *entities.Transaction,
+	value string,
+	catalog *catalog,
+) bool {
+	if t.IsTransfer() {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("a transfer has no subcategory"))
+		return false
+	}
+
+	subcategory, rule := "", ""
+	for _, s := range catalog.subcategories {
+		if s.Name == value {
+			subcategory, rule = s.Name, ManualCategorization
+		}
+	}
+
+	if subcategory == "" {
+		alias, err := redisClient.ParseSubcategory(value)
+		if err == nil && alias != "" {
+			subcategory, rule = alias, "alias:"+value
+		}
+	}
+
+	if subcategory == "" {
+		app_msgs.SendBadRequest(
+			&w,
+			app_msgs.InvalidBody("there is no subcategory named or aliased "+value),
+		)
+		return false
+	}
+
+	t.Subcategory = subcategory
+	t.Category = catalog.categoryOf[subcategory]
+	t.CategorizedBy = rule
+
+	return true
+}
+
+// applyTransactionAccount sets the account by name, an empty name removes
+// it. Accounts follow the same rules as the @account of task titles.
+func applyTransactionAccount(
+	w http.ResponseWriter,
+	t *entities.Transaction,
+	value string,
+	catalog *catalog,
+) bool {
+	name := strings.TrimPrefix(strings.TrimSpace(value), "@")
+
+	if len(strings.Fields(name)) > 1 {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("account must not contain spaces"))
+		return false
+	}
+
+	if name == "" && t.IsTransfer() {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("both accounts of a transfer must have a name"))
+		return false
+	}
+
+	t.Account = name
+	t.AccountID = catalog.accountIDs[name]
+
+	return true
+}
+
+// transactionTags validates the tags the same way as the #tags of task
+// titles, leaving out repeated ones.
+func transactionTags(w http.ResponseWriter, values []string) ([]string, bool) {
+	var tags []string
+
+	seen := make(map[string]bool)
+	for _, value := range values {
+		tag := strings.TrimPrefix(strings.TrimSpace(value), "#")
+
+		if tag == "" || len(strings.Fields(tag)) > 1 {
+			app_msgs.SendBadRequest(
+				&w,
+				app_msgs.InvalidBody("tags must have a name without spaces"),
+			)
+			return nil, false
+		}
+
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags, true
+}
+
+// transferSidesOf returns the transaction along with the other side of its
+// transfer, if it is one. The transaction always comes first.
+func transferSidesOf(
+	w http.ResponseWriter,
+	transaction *entities.Transaction,
+) ([]entities.Transaction, bool) {
+	sides := []entities.Transaction{*transaction}
+
+	if !transaction.IsTransfer() {
+		return sides, true
+	}
+
+	page, err := transactionRepository.QueryTransactions(
+		models.TransactionQuery{
+			TransferID:     transaction.TransferID,
+			IncludeDeleted: true,
+		},
+	)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return nil, false
+	}
+
+	for _, t := range page.Transactions {
+		if t.ID != transaction.ID {
+			sides = append(sides, t)
+		}
+	}
+
+	return sides, true
+}
+
+// updateTransactions stores the transactions all at once, only if none was
+// modified since it was read, and returns them as stored. They are recorded
+// in the audit log along with how they were before.
+func updateTransactions(
+	w http.ResponseWriter,
+	r *http.Request,
+	before []entities.Transaction,
+	transactions []entities.Transaction,
+) ([]entities.Transaction, bool) {
+	modifiedAt := time.Now().UTC().Truncate(time.Millisecond)
+
+	lastModified := make(map[primitive.ObjectID]time.Time, len(transactions))
+	for i := range transactions {
+		lastModified[transactions[i].ID] = transactions[i].ModifiedAt
+		transactions[i].ModifiedAt = modifiedAt
+	}
+
+	err := transactionRepository.UpdateTransactions(transactions, lastModified)
+	if errors.Is(err, databases.ErrModified) {
+		sendTransactionModified(w, &transactions[0])
+		return nil, false
+	}
+
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return nil, false
+	}
+
+	changes := make([]audit.Change, len(transactions))
+	for i := range transactions {
+		changes[i] = audit.Change{Before: &before[i], After: &transactions[i]}
+	}
+
+	audit.Record(auditRepository, entities.AuditSourceAPI, requestUser(r), changes...)
+
+	return transactions, true
+}
+
+// matchesModifiedAt checks the optional modifiedAt query parameter against
+// the transaction.
+func matchesModifiedAt(w http.ResponseWriter, r *http.Request, transaction *entities.Transaction) bool {
+	value := r.URL.Query().Get("modifiedAt")
+	if value == "" {
+		return true
+	}
+
+	modifiedAt, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		app_msgs.SendBadRequest(
+			&w,
+			app_msgs.InvalidQueryParameter("modifiedAt", value, "must be an RFC 3339 timestamp"),
+		)
+		return false
+	}
+
+	if !transaction.ModifiedAt.Equal(modifiedAt) {
+		sendTransactionModified(w, transaction)
+		return false
+	}
+
+	return true
+}
+
+func sendTransactionModified(w http.ResponseWriter, transaction *entities.Transaction) {
+	app_msgs.SendConflict(&w, app_msgs.TransactionModified(transaction.ID.Hex()))
+}
+
+func findTransaction(w http.ResponseWriter, id string) (*entities.Transaction, bool) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidID(id))
+		return nil, false
+	}
+
+	transaction, err := transactionRepository.GetTransaction(objectID)
+	if errors.Is(err, databases.ErrNotFound) {
+		app_msgs.SendNotFound(&w, app_msgs.NotFound("transaction", id))
+		return nil, false
+	}
+
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return nil, false
+	}
+
+	return transaction, true
+}
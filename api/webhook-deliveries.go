@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/app_msgs"
+	"github.com/jbonadiman/finances-api/internal/databases"
+	"github.com/jbonadiman/finances-api/internal/entities"
+	"github.com/jbonadiman/finances-api/internal/webhooks"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500
+)
+
+// ManageWebhookDeliveries lists (GET) the latest deliveries, newest first,
+// with the payload and the outcome of their last attempt. The optional query
+// parameters are:
+//
+//	webhookId   only the deliveries of this webhook
+//	status      pending, delivered or failed
+//	limit       how many deliveries, 50 by default and at most 500
+//
+// POST retries the pending deliveries that are due. The long running server
+// does it on a schedule, this lets a cron job do it for serverless
+// deployments.
+func ManageWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if !authenticate(w, r) {
+		return
+	}
+
+	if webhookRepository == nil {
+		app_msgs.SendNotImplemented(&w, databases.ErrMongoRequired.Error())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		listWebhookDeliveries(w, r)
+	case http.MethodPost:
+		err := webhooks.RetryDue(webhookRepository, time.Now().UTC())
+		if err != nil {
+			app_msgs.SendInternalError(&w, err.Error())
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		app_msgs.SendMethodNotAllowed(&w, http.MethodGet, http.MethodPost)
+	}
+}
+
+func listWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	var webhookID primitive.ObjectID
+	if id := params.Get("webhookId"); id != "" {
+		var err error
+
+		webhookID, err = primitive.ObjectIDFromHex(id)
+		if err != nil {
+			app_msgs.SendBadRequest(&w, app_msgs.InvalidID(id))
+			return
+		}
+	}
+
+	status := params.Get("status")
+	if status != "" && status != entities.DeliveryPending &&
+		status != entities.DeliveryDelivered && status != entities.DeliveryFailed {
+		app_msgs.SendBadRequest(
+			&w,
+			app_msgs.InvalidQueryParameter("status", status, "must be pending, delivered or failed"),
+		)
+		return
+	}
+
+	limit := defaultDeliveriesLimit
+	if value := params.Get("limit"); value != "" {
+		var err error
+
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxDeliveriesLimit {
+			app_msgs.SendBadRequest(
+				&w,
+				app_msgs.InvalidQueryParameter("limit", value, "must be between 1 and 500"),
+			)
+			return
+		}
+	}
+
+	deliveries, err := webhookRepository.GetDeliveries(webhookID, status, limit)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	app_msgs.SendJSON(&w, deliveries, http.StatusOK)
+}
@@ -0,0 +1,255 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/app_msgs"
+	"github.com/jbonadiman/finances-api/internal/databases"
+	"github.com/jbonadiman/finances-api/internal/entities"
+	"github.com/jbonadiman/finances-api/internal/webhooks"
+)
+
+var webhookRepository databases.WebhookRepository
+
+type webhookRequest struct {
+	URL    *string   `json:"url"`
+	Secret *string   `json:"secret"`
+	Events *[]string `json:"events"`
+	Active *bool     `json:"active"`
+}
+
+func init() {
+	var err error
+
+	webhookRepository, err = databases.GetWebhookRepository()
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// ManageWebhooks lists (GET), registers (POST), updates (PATCH) and removes
+// (DELETE) the webhooks. Updates and removals take the webhook in the id
+// query parameter. A webhook subscribes to the events it lists, or to every
+// event when it lists none. Its secret is generated when not given, and is
+// only shown when it is registered or changed.
+func ManageWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !authenticate(w, r) {
+		return
+	}
+
+	if webhookRepository == nil {
+		app_msgs.SendNotImplemented(&w, databases.ErrMongoRequired.Error())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		listWebhooks(w)
+	case http.MethodPost:
+		createWebhook(w, r)
+	case http.MethodPatch:
+		updateWebhook(w, r)
+	case http.MethodDelete:
+		deleteWebhook(w, r)
+	default:
+		app_msgs.SendMethodNotAllowed(
+			&w,
+			http.MethodGet,
+			http.MethodPost,
+			http.MethodPatch,
+			http.MethodDelete,
+		)
+	}
+}
+
+func listWebhooks(w http.ResponseWriter) {
+	registered, err := webhookRepository.GetAllWebhooks()
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	for i := range *registered {
+		(*registered)[i].Secret = ""
+	}
+
+	app_msgs.SendJSON(&w, registered, http.StatusOK)
+}
+
+func createWebhook(w http.ResponseWriter, r *http.Request) {
+	var request webhookRequest
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody(err.Error()))
+		return
+	}
+
+	if request.URL == nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("url is required"))
+		return
+	}
+
+	webhook := entities.Webhook{
+		ID:        primitive.NewObjectID(),
+		Events:    []string{},
+		Active:    true,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if request.Secret == nil {
+		webhook.Secret, err = webhooks.NewSecret()
+		if err != nil {
+			app_msgs.SendInternalError(&w, err.Error())
+			return
+		}
+	}
+
+	if !applyWebhookRequest(w, &webhook, &request) {
+		return
+	}
+
+	err = webhookRepository.StoreWebhook(&webhook)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	app_msgs.SendJSON(&w, webhook, http.StatusCreated)
+}
+
+func updateWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := findWebhook(w, r.URL.Query().Get("id"))
+	if !ok {
+		return
+	}
+
+	var request webhookRequest
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidBody(err.Error()))
+		return
+	}
+
+	if !applyWebhookRequest(w, webhook, &request) {
+		return
+	}
+
+	err = webhookRepository.UpdateWebhook(webhook)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	if request.Secret == nil {
+		webhook.Secret = ""
+	}
+
+	app_msgs.SendJSON(&w, webhook, http.StatusOK)
+}
+
+func deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := findWebhook(w, r.URL.Query().Get("id"))
+	if !ok {
+		return
+	}
+
+	err := webhookRepository.DeleteWebhook(webhook.ID)
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyWebhookRequest validates the fields present in the request and sets
+// them on the webhook.
+func applyWebhookRequest(
+	w http.ResponseWriter,
+	webhook *entities.Webhook,
+	request *webhookRequest,
+) bool {
+	if request.URL != nil {
+		address := strings.TrimSpace(*request.URL)
+
+		parsed, err := url.Parse(address)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") ||
+			parsed.Host == "" {
+			app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("url must be an absolute http or https URL"))
+			return false
+		}
+
+		webhook.URL = address
+	}
+
+	if request.Secret != nil {
+		if *request.Secret == "" {
+			app_msgs.SendBadRequest(&w, app_msgs.InvalidBody("secret must not be empty"))
+			return false
+		}
+
+		webhook.Secret = *request.Secret
+	}
+
+	if request.Events != nil {
+		for _, event := range *request.Events {
+			if !isEvent(event) {
+				app_msgs.SendBadRequest(
+					&w,
+					app_msgs.InvalidBody("unknown event "+event+", must be one of "+
+						strings.Join(entities.Events, ", ")),
+				)
+				return false
+			}
+		}
+
+		webhook.Events = *request.Events
+	}
+
+	if request.Active != nil {
+		webhook.Active = *request.Active
+	}
+
+	return true
+}
+
+func isEvent(event string) bool {
+	for _, e := range entities.Events {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+func findWebhook(w http.ResponseWriter, id string) (*entities.Webhook, bool) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		app_msgs.SendBadRequest(&w, app_msgs.InvalidID(id))
+		return nil, false
+	}
+
+	webhook, err := webhookRepository.GetWebhook(objectID)
+	if errors.Is(err, databases.ErrNotFound) {
+		app_msgs.SendNotFound(&w, app_msgs.NotFound("webhook", id))
+		return nil, false
+	}
+
+	if err != nil {
+		app_msgs.SendInternalError(&w, err.Error())
+		return nil, false
+	}
+
+	return webhook, true
+}
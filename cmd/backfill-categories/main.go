@@ -0,0 +1,94 @@
+// Command backfill-categories fills the category of stored transactions
+// from the category of their subcategory. It is safe to run more than once,
+// and the transactions it changes are recorded in the audit log as an
+// import.
+package main
+
+import (
+	"log"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/audit"
+	"github.com/jbonadiman/finances-api/internal/databases"
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+func main() {
+	transactionRepository, err := databases.GetTransactionRepository()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	subcategoryRepository, err := databases.GetSubcategoryRepository()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	categoryRepository, err := databases.GetCategoryRepository()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	auditRepository, err := databases.GetAuditRepository()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	categories, err := categoryRepository.GetAllCategories()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	subcategories, err := subcategoryRepository.GetAllSubcategories()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	subcategoriesOf := make(map[primitive.ObjectID][]string)
+	for _, s := range *subcategories {
+		subcategoriesOf[s.CategoryID] = append(subcategoriesOf[s.CategoryID], s.Name)
+	}
+
+	var total int64
+	for _, c := range *categories {
+		names := subcategoriesOf[c.ID]
+		if len(names) == 0 {
+			continue
+		}
+
+		quoted := make([]string, len(names))
+		for i, name := range names {
+			quoted[i] = regexp.QuoteMeta(name)
+		}
+
+		transactions, err := transactionRepository.GetTransactionBySubcategory(
+			"^(?:" + strings.Join(quoted, "|") + ")$",
+		)
+		if err != nil {
+			log.Fatalf("could not backfill category %q: %v\n", c.Name, err)
+		}
+
+		changed, err := transactionRepository.SetCategory(names, c.Name)
+		if err != nil {
+			log.Fatalf("could not backfill category %q: %v\n", c.Name, err)
+		}
+
+		category := c.Name
+		audit.Record(
+			auditRepository,
+			entities.AuditSourceImport,
+			"",
+			audit.Changes(*transactions, func(t *entities.Transaction) {
+				t.Category = category
+			})...,
+		)
+
+		log.Printf("set category %q on %v transactions\n", c.Name, changed)
+		total += changed
+	}
+
+	log.Printf("backfilled the category of %v transactions\n", total)
+}
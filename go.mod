@@ -4,6 +4,8 @@ go 1.15
 
 require (
 	github.com/go-redis/redis/v8 v8.4.9
+	github.com/mattn/go-sqlite3 v1.14.6
 	go.mongodb.org/mongo-driver v1.4.5
 	golang.org/x/oauth2 v0.0.0-20210113205817-d3ed898aa8a3
+	golang.org/x/text v0.3.3
 )
@@ -1,8 +1,10 @@
 package app_msgs
 
 import (
+	"encoding/json"
 	"log"
 	"net/http"
+	"strings"
 )
 
 func SendInternalError(w *http.ResponseWriter, msg string) {
@@ -17,4 +19,36 @@ func SendBadRequest(w *http.ResponseWriter, msg string) {
 
 func sendError(w *http.ResponseWriter, msg string, httpCode int) {
 	http.Error(*w, msg, httpCode)
-}
\ No newline at end of file
+}
+
+func SendNotFound(w *http.ResponseWriter, msg string) {
+	log.Println(msg)
+	sendError(w, msg, http.StatusNotFound)
+}
+
+func SendConflict(w *http.ResponseWriter, msg string) {
+	log.Println(msg)
+	sendError(w, msg, http.StatusConflict)
+}
+
+func SendMethodNotAllowed(w *http.ResponseWriter, allowed ...string) {
+	(*w).Header().Set("Allow", strings.Join(allowed, ", "))
+	sendError(w, methodNotAllowed, http.StatusMethodNotAllowed)
+}
+
+func SendNotImplemented(w *http.ResponseWriter, msg string) {
+	log.Println(msg)
+	sendError(w, msg, http.StatusNotImplemented)
+}
+
+func SendJSON(w *http.ResponseWriter, value interface{}, httpCode int) {
+	valueAsJson, err := json.Marshal(value)
+	if err != nil {
+		SendInternalError(w, err.Error())
+		return
+	}
+
+	(*w).Header().Set("Content-Type", "application/json")
+	(*w).WriteHeader(httpCode)
+	_, _ = (*w).Write(valueAsJson)
+}
@@ -13,14 +13,28 @@ const (
 	errorAuthenticating      = "an error occurred during authentication: %v\n"
 	redisConnectionError     = "an error occurred while connecting to Redis: %v\n"
 	notAuthenticated         = "there is no token information saved. Please, authenticate"
+	invalidQueryParameter    = "error: invalid value %q for the %q query parameter: %v"
+	invalidBody              = "error: invalid request body: %v"
+	invalidID                = "error: %q is not a valid id"
+	notFound                 = "error: could not find %v %q"
+	methodNotAllowed         = "error: method not allowed"
+	transactionModified      = "error: transaction %q was modified since it was read, read it again before changing it"
+	transactionDeleted       = "error: transaction %q is deleted, restore it before changing it"
 )
 
 // Successes
 const (
 	allTransactionsStored = "all %v transactions were stored successfully!\n"
+	transactionsIngested  = "ingested %v transactions: %v new, %v already ingested, %v updated\n"
 	allTasksCompleted     = "marked %v tasks as completed!\n"
 )
 
+// Alerts
+const (
+	budgetAlertTitle = "%v budget of %v reached %v%%"
+	budgetAlertNote  = "spent %.2f of %.2f (%.2f%%), %.2f projected by the end of the month"
+)
+
 func MsCredentials() string {
 	return msCredentials
 }
@@ -64,3 +78,39 @@ func AllTransactionsStored(count int) string {
 func AllTasksCompleted(count int) string {
 	return fmt.Sprintf(allTasksCompleted, count)
 }
+
+func TransactionsIngested(total int, new int, alreadyIngested int, updated int) string {
+	return fmt.Sprintf(transactionsIngested, total, new, alreadyIngested, updated)
+}
+
+func InvalidQueryParameter(name string, value string, reason string) string {
+	return fmt.Sprintf(invalidQueryParameter, value, name, reason)
+}
+
+func InvalidBody(reason string) string {
+	return fmt.Sprintf(invalidBody, reason)
+}
+
+func InvalidID(id string) string {
+	return fmt.Sprintf(invalidID, id)
+}
+
+func NotFound(kind string, id string) string {
+	return fmt.Sprintf(notFound, kind, id)
+}
+
+func TransactionModified(id string) string {
+	return fmt.Sprintf(transactionModified, id)
+}
+
+func TransactionDeleted(id string) string {
+	return fmt.Sprintf(transactionDeleted, id)
+}
+
+func BudgetAlertTitle(name string, month string, threshold int) string {
+	return fmt.Sprintf(budgetAlertTitle, month, name, threshold)
+}
+
+func BudgetAlertNote(spent float64, limit float64, percent float64, projected float64) string {
+	return fmt.Sprintf(budgetAlertNote, spent, limit, percent, projected)
+}
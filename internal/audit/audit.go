@@ -0,0 +1,242 @@
+// Package audit keeps the history of the changes to every transaction. Each
+// change is appended to the audit log with who made it, when, from where and
+// the value of each field it changed, before and after.
+package audit
+
+import (
+	"encoding/json"
+	"log"
+	"reflect"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+	"github.com/jbonadiman/finances-api/internal/models"
+)
+
+// fields left out of the changes, the entry already says which transaction
+// changed and when
+var ignoredFields = map[string]bool{
+	"id":         true,
+	"modifiedAt": true,
+}
+
+var categorizationFields = map[string]bool{
+	"category":      true,
+	"subcategory":   true,
+	"categorizedBy": true,
+}
+
+// Repository appends entries to the audit log.
+type Repository interface {
+	StoreAuditEntries(entries ...entities.AuditEntry) error
+}
+
+// Change is a transaction before and after being changed. Before is nil for
+// new transactions and After is nil for the ones removed for good.
+type Change struct {
+	Before *entities.Transaction
+	After  *entities.Transaction
+}
+
+// Record appends an entry for each change that changed something. Nothing is
+// recorded without a repository, as the audit log is not available, and
+// failures are only logged, so auditing never gets in the way of the change
+// itself.
+func Record(repository Repository, source string, user string, changes ...Change) {
+	if repository == nil {
+		return
+	}
+
+	now := time.Now().UTC()
+
+	var entries []entities.AuditEntry
+	for _, change := range changes {
+		entry, err := NewEntry(source, user, change, now)
+		if err != nil {
+			log.Printf("could not audit a change from %v: %v\n", source, err)
+			continue
+		}
+
+		if entry != nil {
+			entries = append(entries, *entry)
+		}
+	}
+
+	if len(entries) == 0 {
+		return
+	}
+
+	err := repository.StoreAuditEntries(entries...)
+	if err != nil {
+		log.Printf("could not store %v audit entries from %v: %v\n", len(entries), source, err)
+	}
+}
+
+// NewEntry builds the audit entry of the change, or nil when it changed
+// nothing.
+func NewEntry(
+	source string,
+	user string,
+	change Change,
+	at time.Time,
+) (*entities.AuditEntry, error) {
+	changes, err := Diff(change.Before, change.After)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	var transactionID primitive.ObjectID
+	if change.After != nil {
+		transactionID = change.After.ID
+	} else {
+		transactionID = change.Before.ID
+	}
+
+	return &entities.AuditEntry{
+		ID:            primitive.NewObjectID(),
+		TransactionID: transactionID,
+		Action:        Action(change, changes),
+		Source:        source,
+		User:          user,
+		At:            at,
+		Changes:       changes,
+	}, nil
+}
+
+// Action tells what kind of change it was from the fields it changed.
+func Action(change Change, changes []entities.AuditChange) string {
+	switch {
+	case change.Before == nil:
+		return entities.AuditInsert
+	case change.After == nil:
+		return entities.AuditDelete
+	case !change.Before.IsDeleted() && change.After.IsDeleted():
+		return entities.AuditDelete
+	case change.Before.IsDeleted() && !change.After.IsDeleted():
+		return entities.AuditRestore
+	}
+
+	for _, c := range changes {
+		if !categorizationFields[c.Field] {
+			return entities.AuditUpdate
+		}
+	}
+
+	return entities.AuditRecategorize
+}
+
+// Diff returns the fields that differ between the transactions, as they are
+// shown by the API, sorted by name. Either of them may be nil.
+func Diff(before, after *entities.Transaction) ([]entities.AuditChange, error) {
+	beforeFields, err := fieldsOf(before)
+	if err != nil {
+		return nil, err
+	}
+
+	afterFields, err := fieldsOf(after)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for name := range beforeFields {
+		names[name] = true
+	}
+	for name := range afterFields {
+		names[name] = true
+	}
+
+	changes := make([]entities.AuditChange, 0)
+	for name := range names {
+		if ignoredFields[name] ||
+			reflect.DeepEqual(beforeFields[name], afterFields[name]) {
+			continue
+		}
+
+		changes = append(
+			changes,
+			entities.AuditChange{
+				Field:  name,
+				Before: beforeFields[name],
+				After:  afterFields[name],
+			},
+		)
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+
+	return changes, nil
+}
+
+func fieldsOf(t *entities.Transaction) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if t == nil {
+		return fields, nil
+	}
+
+	raw, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(raw, &fields)
+	return fields, err
+}
+
+// StoredChanges returns the changes the store result reports, the stored
+// transactions keeping the ID, creation and deletion of the ones they
+// updated.
+func StoredChanges(
+	transactions []entities.Transaction,
+	result models.StoreResult,
+) []Change {
+	byTaskID := make(map[string]entities.Transaction, len(transactions))
+	for _, t := range transactions {
+		byTaskID[t.OriginalTaskID] = t
+	}
+
+	changes := make([]Change, 0, len(result.Changes))
+	for _, stored := range result.Changes {
+		after, found := byTaskID[stored.TaskID]
+		if !found {
+			continue
+		}
+
+		after.ID = stored.ID
+
+		if stored.Previous != nil {
+			after.CreatedAt = stored.Previous.CreatedAt
+			after.DeletedAt = stored.Previous.DeletedAt
+		}
+
+		changes = append(changes, Change{Before: stored.Previous, After: &after})
+	}
+
+	return changes
+}
+
+// Changes applies the change to a copy of each transaction, returning them
+// before and after it.
+func Changes(
+	transactions []entities.Transaction,
+	apply func(t *entities.Transaction),
+) []Change {
+	changes := make([]Change, len(transactions))
+	for i := range transactions {
+		after := transactions[i]
+		apply(&after)
+
+		changes[i] = Change{Before: &transactions[i], After: &after}
+	}
+
+	return changes
+}
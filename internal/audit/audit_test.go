@@ -0,0 +1,255 @@
+package audit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+var (
+	createdAt = time.Date(2021, 2, 15, 10, 0, 0, 0, time.UTC)
+	deletedAt = time.Date(2021, 2, 16, 10, 0, 0, 0, time.UTC)
+)
+
+func newTransaction() entities.Transaction {
+	return entities.Transaction{
+		ID:             primitive.NewObjectID(),
+		OriginalTaskID: "task-1",
+		Date:           createdAt,
+		CreatedAt:      createdAt,
+		ModifiedAt:     createdAt,
+		Description:    "pizza",
+		Cost:           42.5,
+		Direction:      entities.DirectionExpense,
+		Category:       "comida",
+		Subcategory:    "delivery",
+	}
+}
+
+// changed returns a copy of the transaction modified later with the change
+// applied.
+func changed(t entities.Transaction, apply func(t *entities.Transaction)) *entities.Transaction {
+	t.ModifiedAt = t.ModifiedAt.Add(time.Hour)
+	apply(&t)
+
+	return &t
+}
+
+func TestDiff(t *testing.T) {
+	before := newTransaction()
+	deleted := changed(before, func(t *entities.Transaction) { t.DeletedAt = &deletedAt })
+
+	tests := []struct {
+		name   string
+		before *entities.Transaction
+		after  *entities.Transaction
+		want   []entities.AuditChange
+	}{
+		{
+			name:   "nothing changed",
+			before: &before,
+			after:  changed(before, func(t *entities.Transaction) {}),
+			want:   []entities.AuditChange{},
+		},
+		{
+			name:   "id and modifiedAt are ignored",
+			before: &before,
+			after:  changed(before, func(t *entities.Transaction) { t.ID = primitive.NewObjectID() }),
+			want:   []entities.AuditChange{},
+		},
+		{
+			name:   "update",
+			before: &before,
+			after: changed(before, func(t *entities.Transaction) {
+				t.Description = "pizza grande"
+				t.Cost = 50
+			}),
+			want: []entities.AuditChange{
+				{Field: "description", Before: "pizza", After: "pizza grande"},
+				{Field: "value", Before: 42.5, After: 50.0},
+			},
+		},
+		{
+			name:   "recategorize",
+			before: &before,
+			after: changed(before, func(t *entities.Transaction) {
+				t.Subcategory = "restaurante"
+				t.CategorizedBy = "keyword:pizza"
+			}),
+			want: []entities.AuditChange{
+				{Field: "categorizedBy", Before: nil, After: "keyword:pizza"},
+				{Field: "subcategory", Before: "delivery", After: "restaurante"},
+			},
+		},
+		{
+			name:   "delete",
+			before: &before,
+			after:  deleted,
+			want: []entities.AuditChange{
+				{Field: "deletedAt", Before: nil, After: "2021-02-16T10:00:00Z"},
+			},
+		},
+		{
+			name:   "restore",
+			before: deleted,
+			after:  changed(*deleted, func(t *entities.Transaction) { t.DeletedAt = nil }),
+			want: []entities.AuditChange{
+				{Field: "deletedAt", Before: "2021-02-16T10:00:00Z", After: nil},
+			},
+		},
+		{
+			name:   "neither",
+			before: nil,
+			after:  nil,
+			want:   []entities.AuditChange{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Diff(test.before, test.after)
+			if err != nil {
+				t.Fatalf("Diff returned an error: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Diff() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDiffInsert(t *testing.T) {
+	after := newTransaction()
+
+	changes, err := Diff(nil, &after)
+	if err != nil {
+		t.Fatalf("Diff returned an error: %v", err)
+	}
+
+	fields := make(map[string]entities.AuditChange)
+	for _, change := range changes {
+		if change.Before != nil {
+			t.Errorf("inserted field %v was %v before", change.Field, change.Before)
+		}
+
+		fields[change.Field] = change
+	}
+
+	if got := fields["description"].After; got != "pizza" {
+		t.Errorf("inserted the description %v, want pizza", got)
+	}
+
+	for _, ignored := range []string{"id", "modifiedAt"} {
+		if _, found := fields[ignored]; found {
+			t.Errorf("inserted the ignored field %v", ignored)
+		}
+	}
+}
+
+func TestAction(t *testing.T) {
+	before := newTransaction()
+	deleted := changed(before, func(t *entities.Transaction) { t.DeletedAt = &deletedAt })
+
+	tests := []struct {
+		name   string
+		change Change
+		want   string
+	}{
+		{
+			name:   "insert",
+			change: Change{After: &before},
+			want:   entities.AuditInsert,
+		},
+		{
+			name:   "update",
+			change: Change{Before: &before, After: changed(before, func(t *entities.Transaction) { t.Cost = 50 })},
+			want:   entities.AuditUpdate,
+		},
+		{
+			name: "update along with the category",
+			change: Change{Before: &before, After: changed(before, func(t *entities.Transaction) {
+				t.Category = "lazer"
+				t.Description = "pizza grande"
+			})},
+			want: entities.AuditUpdate,
+		},
+		{
+			name: "recategorize",
+			change: Change{Before: &before, After: changed(before, func(t *entities.Transaction) {
+				t.Category = "lazer"
+				t.Subcategory = "restaurante"
+				t.CategorizedBy = "keyword:pizza"
+			})},
+			want: entities.AuditRecategorize,
+		},
+		{
+			name:   "soft delete",
+			change: Change{Before: &before, After: deleted},
+			want:   entities.AuditDelete,
+		},
+		{
+			name:   "removed for good",
+			change: Change{Before: &before},
+			want:   entities.AuditDelete,
+		},
+		{
+			name:   "restore",
+			change: Change{Before: deleted, After: changed(*deleted, func(t *entities.Transaction) { t.DeletedAt = nil })},
+			want:   entities.AuditRestore,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changes, err := Diff(test.change.Before, test.change.After)
+			if err != nil {
+				t.Fatalf("Diff returned an error: %v", err)
+			}
+
+			if got := Action(test.change, changes); got != test.want {
+				t.Errorf("Action() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+type fakeRepository struct {
+	entries []entities.AuditEntry
+}
+
+func (r *fakeRepository) StoreAuditEntries(entries ...entities.AuditEntry) error {
+	r.entries = append(r.entries, entries...)
+	return nil
+}
+
+func TestRecord(t *testing.T) {
+	before := newTransaction()
+	after := changed(before, func(t *entities.Transaction) { t.Cost = 50 })
+	unchanged := changed(before, func(t *entities.Transaction) {})
+
+	repository := &fakeRepository{}
+	Record(
+		repository,
+		entities.AuditSourceAPI,
+		"user",
+		Change{Before: &before, After: after},
+		Change{Before: &before, After: unchanged},
+	)
+
+	if len(repository.entries) != 1 {
+		t.Fatalf("recorded %v entries, want 1", len(repository.entries))
+	}
+
+	entry := repository.entries[0]
+	if entry.TransactionID != before.ID ||
+		entry.Action != entities.AuditUpdate ||
+		entry.Source != entities.AuditSourceAPI ||
+		entry.User != "user" {
+		t.Errorf("recorded %+v", entry)
+	}
+}
@@ -0,0 +1,102 @@
+// Package categorizer guesses the subcategory of a transaction by looking
+// for the keywords of each subcategory in its description.
+package categorizer
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+type Match struct {
+	Subcategory string
+	Keywords    []string
+	Score       int
+	length      int
+}
+
+// Rule describes why the match was chosen, to be stored on the transaction.
+func (m *Match) Rule() string {
+	return "keyword:" + strings.Join(m.Keywords, ",")
+}
+
+// Categorize returns the subcategory whose keywords best match the
+// description, or nil when no keyword matches. Keywords only match whole
+// words, ignoring case and accents. Every word of a matched keyword scores a
+// point, so "posto ipiranga" beats "posto". Ties are broken by the length of
+// the matched keywords and then by the subcategory name.
+func Categorize(
+	description string,
+	subcategories []entities.Subcategory,
+) *Match {
+	words := " " + strings.Join(tokenize(description), " ") + " "
+
+	var matches []Match
+	for _, s := range subcategories {
+		match := Match{Subcategory: s.Name}
+
+		for _, keyword := range s.Keywords {
+			keywordWords := tokenize(keyword)
+			if len(keywordWords) == 0 {
+				continue
+			}
+
+			normalized := strings.Join(keywordWords, " ")
+			if strings.Contains(words, " "+normalized+" ") {
+				match.Keywords = append(match.Keywords, keyword)
+				match.Score += len(keywordWords)
+				match.length += len(normalized)
+			}
+		}
+
+		if match.Score > 0 {
+			matches = append(matches, match)
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+
+		if matches[i].length != matches[j].length {
+			return matches[i].length > matches[j].length
+		}
+
+		return matches[i].Subcategory < matches[j].Subcategory
+	})
+
+	return &matches[0]
+}
+
+// Normalize lower cases the text and removes its accents.
+func Normalize(text string) string {
+	t := transform.Chain(
+		norm.NFD,
+		runes.Remove(runes.In(unicode.Mn)),
+		norm.NFC,
+	)
+
+	normalized, _, err := transform.String(t, text)
+	if err != nil {
+		normalized = text
+	}
+
+	return strings.ToLower(normalized)
+}
+
+func tokenize(text string) []string {
+	return strings.FieldsFunc(Normalize(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,111 @@
+package categorizer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+func subcategory(name string, keywords ...string) entities.Subcategory {
+	return entities.Subcategory{Name: name, Keywords: keywords}
+}
+
+func TestCategorize(t *testing.T) {
+	tests := []struct {
+		name          string
+		description   string
+		subcategories []entities.Subcategory
+		want          *Match
+	}{
+		{
+			name:          "no match",
+			description:   "pizza",
+			subcategories: []entities.Subcategory{subcategory("mercado", "arroz")},
+			want:          nil,
+		},
+		{
+			name:          "accents and case",
+			description:   "CAFÉ da Manhã",
+			subcategories: []entities.Subcategory{subcategory("padaria", "cafe da manha")},
+			want:          &Match{Subcategory: "padaria", Keywords: []string{"cafe da manha"}, Score: 3, length: 13},
+		},
+		{
+			name:          "accented keyword",
+			description:   "pao de acucar",
+			subcategories: []entities.Subcategory{subcategory("mercado", "Pão de Açúcar")},
+			want:          &Match{Subcategory: "mercado", Keywords: []string{"Pão de Açúcar"}, Score: 3, length: 13},
+		},
+		{
+			name:          "whole words only",
+			description:   "postos shell",
+			subcategories: []entities.Subcategory{subcategory("combustivel", "posto")},
+			want:          nil,
+		},
+		{
+			name:          "punctuation separates words",
+			description:   "posto-shell, centro",
+			subcategories: []entities.Subcategory{subcategory("combustivel", "posto")},
+			want:          &Match{Subcategory: "combustivel", Keywords: []string{"posto"}, Score: 1, length: 5},
+		},
+		{
+			name:        "more words win",
+			description: "posto ipiranga",
+			subcategories: []entities.Subcategory{
+				subcategory("combustivel", "posto"),
+				subcategory("conveniencia", "posto ipiranga"),
+			},
+			want: &Match{Subcategory: "conveniencia", Keywords: []string{"posto ipiranga"}, Score: 2, length: 14},
+		},
+		{
+			name:        "keywords add up",
+			description: "uber eats pizza",
+			subcategories: []entities.Subcategory{
+				subcategory("delivery", "pizza", "uber eats"),
+				subcategory("transporte", "uber"),
+			},
+			want: &Match{Subcategory: "delivery", Keywords: []string{"pizza", "uber eats"}, Score: 3, length: 14},
+		},
+		{
+			name:        "longer keywords break ties",
+			description: "farmacia bar",
+			subcategories: []entities.Subcategory{
+				subcategory("bar", "bar"),
+				subcategory("saude", "farmacia"),
+			},
+			want: &Match{Subcategory: "saude", Keywords: []string{"farmacia"}, Score: 1, length: 8},
+		},
+		{
+			name:        "names break ties",
+			description: "pizza",
+			subcategories: []entities.Subcategory{
+				subcategory("restaurante", "pizza"),
+				subcategory("delivery", "pizza"),
+			},
+			want: &Match{Subcategory: "delivery", Keywords: []string{"pizza"}, Score: 1, length: 5},
+		},
+		{
+			name:          "empty keyword",
+			description:   "pizza",
+			subcategories: []entities.Subcategory{subcategory("delivery", "", "!!")},
+			want:          nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Categorize(test.description, test.subcategories)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Categorize(%q) = %+v, want %+v", test.description, got, test.want)
+			}
+		})
+	}
+}
+
+func TestMatchRule(t *testing.T) {
+	match := Match{Keywords: []string{"posto", "uber eats"}}
+
+	if got, want := match.Rule(), "keyword:posto,uber eats"; got != want {
+		t.Errorf("Rule() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,446 @@
+package memory
+
+import (
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+	"github.com/jbonadiman/finances-api/internal/models"
+)
+
+// DB keeps transactions in process memory. It is meant for tests and local
+// development, everything stored is lost when the process exits.
+type DB struct {
+	mu           sync.RWMutex
+	transactions []entities.Transaction
+	byTaskID     map[string]int
+}
+
+var singleton *DB
+
+func GetDB() (*DB, error) {
+	if singleton == nil {
+		singleton = NewDB()
+	}
+
+	return singleton, nil
+}
+
+func NewDB() *DB {
+	return &DB{
+		transactions: make([]entities.Transaction, 0),
+		byTaskID:     make(map[string]int),
+	}
+}
+
+func (db *DB) StoreTransactions(transactions ...entities.Transaction) (
+	models.StoreResult,
+	error,
+) {
+	var storeResult models.StoreResult
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, t := range transactions {
+		index, found := db.byTaskID[t.OriginalTaskID]
+
+		switch {
+		case !found:
+			db.byTaskID[t.OriginalTaskID] = len(db.transactions)
+			db.transactions = append(db.transactions, t)
+			storeResult.New++
+			storeResult.Created(&t)
+		case db.transactions[index].ModifiedAt.Before(t.ModifiedAt):
+			t.ID = db.transactions[index].ID
+			t.CreatedAt = db.transactions[index].CreatedAt
+			t.DeletedAt = db.transactions[index].DeletedAt
+			storeResult.Updated++
+			storeResult.Replaced(db.transactions[index])
+			db.transactions[index] = t
+		default:
+			storeResult.AlreadyIngested++
+		}
+
+		storeResult.TaskIDs = append(storeResult.TaskIDs, t.OriginalTaskID)
+	}
+
+	return storeResult, nil
+}
+
+// StoreTransfer stores both sides of a transfer under the same lock, so a
+// transfer is never left with a single account.
+func (db *DB) StoreTransfer(out, in entities.Transaction) (
+	models.StoreResult,
+	error,
+) {
+	return db.StoreTransactions(out, in)
+}
+
+// GetTransaction returns the transaction with the ID, even when it was
+// deleted.
+func (db *DB) GetTransaction(id primitive.ObjectID) (*entities.Transaction, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for _, t := range db.transactions {
+		if t.ID == id {
+			return &t, nil
+		}
+	}
+
+	return nil, models.ErrNotFound
+}
+
+// UpdateTransactions replaces the transactions under the same lock, so the
+// sides of a transfer are always changed together. Each one must have been
+// last modified at the time lastModified has for its ID, otherwise someone
+// else changed it since it was read and nothing is replaced, returning
+// ErrModified.
+func (db *DB) UpdateTransactions(
+	transactions []entities.Transaction,
+	lastModified map[primitive.ObjectID]time.Time,
+) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	indexes := make([]int, len(transactions))
+
+	for i, transaction := range transactions {
+		indexes[i] = -1
+
+		for j, t := range db.transactions {
+			if t.ID == transaction.ID {
+				indexes[i] = j
+				break
+			}
+		}
+
+		if indexes[i] < 0 {
+			return models.ErrNotFound
+		}
+
+		if !db.transactions[indexes[i]].ModifiedAt.Equal(lastModified[transaction.ID]) {
+			return models.ErrModified
+		}
+	}
+
+	for i, transaction := range transactions {
+		stored := db.transactions[indexes[i]]
+
+		transaction.OriginalTaskID = stored.OriginalTaskID
+		transaction.CreatedAt = stored.CreatedAt
+		db.transactions[indexes[i]] = transaction
+	}
+
+	return nil
+}
+
+func (db *DB) GetAllTransactions() (*[]entities.Transaction, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	transactions := make([]entities.Transaction, 0, len(db.transactions))
+	for _, t := range db.transactions {
+		if !t.IsDeleted() {
+			transactions = append(transactions, t)
+		}
+	}
+
+	log.Printf(
+		"found %v transactions",
+		len(transactions),
+	)
+
+	return &transactions, nil
+}
+
+func (db *DB) GetTransactionBySubcategory(subRegex string) (
+	*[]entities.Transaction,
+	error,
+) {
+	pattern, err := regexp.Compile(subRegex)
+	if err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var transactions []entities.Transaction
+	for _, t := range db.transactions {
+		if !t.IsDeleted() && pattern.MatchString(t.Subcategory) {
+			transactions = append(transactions, t)
+		}
+	}
+
+	log.Printf(
+		"found %v transactions with the subcategory %q pattern",
+		len(transactions),
+		subRegex,
+	)
+
+	return &transactions, nil
+}
+
+func (db *DB) QueryTransactions(query models.TransactionQuery) (
+	*models.TransactionPage,
+	error,
+) {
+	var pattern *regexp.Regexp
+	if query.Subcategory != "" {
+		var err error
+
+		pattern, err = regexp.Compile(query.Subcategory)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	terms := strings.Fields(strings.ToLower(query.Description))
+
+	db.mu.RLock()
+	matches := make([]entities.Transaction, 0)
+	for _, t := range db.transactions {
+		if matchesQuery(&t, &query, pattern, terms) {
+			matches = append(matches, t)
+		}
+	}
+	db.mu.RUnlock()
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if query.Descending {
+			return lessBy(query.Sort, &matches[j], &matches[i])
+		}
+
+		return lessBy(query.Sort, &matches[i], &matches[j])
+	})
+
+	total := len(matches)
+
+	start := query.Skip()
+	if start > total {
+		start = total
+	}
+
+	end := total
+	if query.PageSize > 0 && start+query.PageSize < total {
+		end = start + query.PageSize
+	}
+
+	log.Printf(
+		"found %v of %v transactions matching the query",
+		end-start,
+		total,
+	)
+
+	return &models.TransactionPage{
+		Transactions: matches[start:end],
+		Total:        int64(total),
+		Totals:       models.TotalsOf(matches),
+		Page:         query.Page,
+		PageSize:     query.PageSize,
+	}, nil
+}
+
+func matchesQuery(
+	t *entities.Transaction,
+	query *models.TransactionQuery,
+	subcategory *regexp.Regexp,
+	terms []string,
+) bool {
+	if t.IsDeleted() && !query.IncludeDeleted {
+		return false
+	}
+
+	if !query.From.IsZero() && t.Date.Before(query.From) {
+		return false
+	}
+
+	if !query.To.IsZero() && !t.Date.Before(query.To) {
+		return false
+	}
+
+	if query.MinValue != nil && t.Cost < *query.MinValue {
+		return false
+	}
+
+	if query.MaxValue != nil && t.Cost > *query.MaxValue {
+		return false
+	}
+
+	if subcategory != nil && !subcategory.MatchString(t.Subcategory) {
+		return false
+	}
+
+	if !query.AccountID.IsZero() && t.AccountID != query.AccountID {
+		return false
+	}
+
+	if !query.InstallmentGroupID.IsZero() &&
+		t.InstallmentGroupID != query.InstallmentGroupID {
+		return false
+	}
+
+	if !query.TransferID.IsZero() && t.TransferID != query.TransferID {
+		return false
+	}
+
+	if !matchesDirection(t, query.Direction) {
+		return false
+	}
+
+	if len(terms) == 0 {
+		return true
+	}
+
+	description := strings.ToLower(t.Description)
+	for _, term := range terms {
+		if strings.Contains(description, term) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesDirection(t *entities.Transaction, direction string) bool {
+	switch direction {
+	case "":
+		return true
+	case entities.DirectionExpense:
+		return t.IsExpense()
+	default:
+		return t.Direction == direction
+	}
+}
+
+func lessBy(field string, a, b *entities.Transaction) bool {
+	switch field {
+	case models.SortByValue:
+		if a.Cost != b.Cost {
+			return a.Cost < b.Cost
+		}
+	case models.SortByDescription:
+		if a.Description != b.Description {
+			return a.Description < b.Description
+		}
+	case models.SortBySubcategory:
+		if a.Subcategory != b.Subcategory {
+			return a.Subcategory < b.Subcategory
+		}
+	case models.SortByCreatedAt:
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+	default:
+		if !a.Date.Equal(b.Date) {
+			return a.Date.Before(b.Date)
+		}
+	}
+
+	return a.ID.Hex() < b.ID.Hex()
+}
+
+func (db *DB) SummarizeTransactions(from, to time.Time) (
+	*models.Summary,
+	error,
+) {
+	page, err := db.QueryTransactions(models.TransactionQuery{From: from, To: to})
+	if err != nil {
+		return nil, err
+	}
+
+	return models.Summarize(page.Transactions), nil
+}
+
+func (db *DB) RenameSubcategory(names []string, newName string) (int64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	modifiedAt := time.Now().UTC().Truncate(time.Millisecond)
+
+	var renamed int64
+	for i := range db.transactions {
+		for _, name := range names {
+			if db.transactions[i].Subcategory == name && name != newName {
+				db.transactions[i].Subcategory = newName
+				db.transactions[i].ModifiedAt = modifiedAt
+				renamed++
+				break
+			}
+		}
+	}
+
+	return renamed, nil
+}
+
+func (db *DB) SetCategory(subcategories []string, category string) (int64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	modifiedAt := time.Now().UTC().Truncate(time.Millisecond)
+
+	var changed int64
+	for i := range db.transactions {
+		for _, subcategory := range subcategories {
+			if db.transactions[i].Subcategory == subcategory &&
+				db.transactions[i].Category != category {
+				db.transactions[i].Category = category
+				db.transactions[i].ModifiedAt = modifiedAt
+				changed++
+				break
+			}
+		}
+	}
+
+	return changed, nil
+}
+
+func (db *DB) LinkAccount(account *entities.Account, previousName string) (
+	int64,
+	error,
+) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	modifiedAt := time.Now().UTC().Truncate(time.Millisecond)
+
+	var changed int64
+	for i := range db.transactions {
+		t := &db.transactions[i]
+
+		linked := t.AccountID == account.ID ||
+			t.Account == account.Name ||
+			(previousName != "" && t.Account == previousName)
+
+		if linked && (t.AccountID != account.ID || t.Account != account.Name) {
+			t.AccountID = account.ID
+			t.Account = account.Name
+			t.ModifiedAt = modifiedAt
+			changed++
+		}
+	}
+
+	return changed, nil
+}
+
+func (db *DB) AccountMovements(
+	query models.TransactionQuery,
+	interval string,
+) ([]models.Movement, error) {
+	query.Page, query.PageSize = 0, 0
+
+	page, err := db.QueryTransactions(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.Movements(page.Transactions, interval), nil
+}
@@ -0,0 +1,118 @@
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+func (db *DB) GetAllAccounts() (*[]entities.Account, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := db.accountsCollection.Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make([]entities.Account, 0)
+
+	err = cursor.All(ctx, &accounts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &accounts, nil
+}
+
+func (db *DB) GetAccount(id primitive.ObjectID) (*entities.Account, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var account entities.Account
+
+	err = db.accountsCollection.FindOne(ctx, bson.M{"_id": id}).
+		Decode(&account)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+func (db *DB) StoreAccount(account *entities.Account) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.accountsCollection.InsertOne(ctx, account)
+	return err
+}
+
+func (db *DB) UpdateAccount(account *entities.Account) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.accountsCollection.ReplaceOne(
+		ctx,
+		bson.M{"_id": account.ID},
+		account,
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) DeleteAccount(id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.accountsCollection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
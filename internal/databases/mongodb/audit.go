@@ -0,0 +1,66 @@
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+func (db *DB) StoreAuditEntries(entries ...entities.AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	documents := make([]interface{}, len(entries))
+	for i := range entries {
+		documents[i] = entries[i]
+	}
+
+	_, err = db.auditCollection.InsertMany(ctx, documents)
+	return err
+}
+
+// GetTransactionHistory returns the audit entries of the transaction, oldest
+// first.
+func (db *DB) GetTransactionHistory(id primitive.ObjectID) (
+	*[]entities.AuditEntry,
+	error,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := db.auditCollection.Find(
+		ctx,
+		bson.M{"transactionId": id},
+		options.Find().SetSort(bson.D{{Key: "at", Value: 1}, {Key: "_id", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]entities.AuditEntry, 0)
+
+	err = cursor.All(ctx, &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entries, nil
+}
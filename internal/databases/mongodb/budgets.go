@@ -0,0 +1,134 @@
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+// GetBudgets returns the budgets of the given months, or every budget when
+// no month is given.
+func (db *DB) GetBudgets(months ...string) (*[]entities.Budget, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{}
+	if len(months) > 0 {
+		filter["month"] = bson.M{"$in": months}
+	}
+
+	cursor, err := db.budgetsCollection.Find(
+		ctx,
+		filter,
+		options.Find().SetSort(
+			bson.D{
+				{Key: "month", Value: 1},
+				{Key: "scope", Value: 1},
+				{Key: "name", Value: 1},
+			},
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	budgets := make([]entities.Budget, 0)
+
+	err = cursor.All(ctx, &budgets)
+	if err != nil {
+		return nil, err
+	}
+
+	return &budgets, nil
+}
+
+func (db *DB) GetBudget(id primitive.ObjectID) (*entities.Budget, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var budget entities.Budget
+
+	err = db.budgetsCollection.FindOne(ctx, bson.M{"_id": id}).
+		Decode(&budget)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &budget, nil
+}
+
+// SetBudget stores the budget, replacing the one of the same subcategory or
+// category and month when there is one. The budget ID is set to the ID of
+// the stored budget.
+func (db *DB) SetBudget(budget *entities.Budget) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return err
+	}
+
+	var stored entities.Budget
+
+	err = db.budgetsCollection.FindOneAndUpdate(
+		ctx,
+		bson.M{"scope": budget.Scope, "name": budget.Name, "month": budget.Month},
+		bson.M{
+			"$set": bson.M{
+				"limit":    budget.Limit,
+				"rollover": budget.Rollover,
+			},
+			"$setOnInsert": bson.M{"_id": budget.ID},
+		},
+		options.FindOneAndUpdate().
+			SetUpsert(true).
+			SetReturnDocument(options.After),
+	).Decode(&stored)
+	if err != nil {
+		return err
+	}
+
+	*budget = stored
+	return nil
+}
+
+func (db *DB) DeleteBudget(id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.budgetsCollection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+func (db *DB) GetAllCategories() (*[]entities.Category, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := db.categoriesCollection.Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+
+	categories := make([]entities.Category, 0)
+
+	err = cursor.All(ctx, &categories)
+	if err != nil {
+		return nil, err
+	}
+
+	return &categories, nil
+}
+
+func (db *DB) GetCategory(id primitive.ObjectID) (*entities.Category, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var category entities.Category
+
+	err = db.categoriesCollection.FindOne(ctx, bson.M{"_id": id}).
+		Decode(&category)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &category, nil
+}
+
+func (db *DB) StoreCategory(category *entities.Category) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.categoriesCollection.InsertOne(ctx, category)
+	return err
+}
+
+func (db *DB) UpdateCategory(category *entities.Category) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.categoriesCollection.ReplaceOne(
+		ctx,
+		bson.M{"_id": category.ID},
+		category,
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) DeleteCategory(id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.categoriesCollection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package mongodb
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -13,15 +14,23 @@ import (
 
 	"github.com/jbonadiman/finances-api/internal/entities"
 	"github.com/jbonadiman/finances-api/internal/environment"
+	"github.com/jbonadiman/finances-api/internal/models"
 	"github.com/jbonadiman/finances-api/internal/utils"
 )
 
 type DB struct {
 	utils.Connection
-	client                  *mongo.Client
-	IsDisconnected          bool
-	transactionsCollection  *mongo.Collection
-	subcategoriesCollection *mongo.Collection
+	client                   *mongo.Client
+	IsDisconnected           bool
+	transactionsCollection   *mongo.Collection
+	subcategoriesCollection  *mongo.Collection
+	categoriesCollection     *mongo.Collection
+	accountsCollection       *mongo.Collection
+	recurringRulesCollection *mongo.Collection
+	budgetsCollection        *mongo.Collection
+	webhooksCollection       *mongo.Collection
+	deliveriesCollection     *mongo.Collection
+	auditCollection          *mongo.Collection
 }
 
 const TimeOut = 5 * time.Second
@@ -72,56 +81,475 @@ func GetDB() (*DB, error) {
 		financesDb := singleton.client.Database("finances")
 		singleton.transactionsCollection = financesDb.Collection("transactions")
 		singleton.subcategoriesCollection = financesDb.Collection("subcategories")
+		singleton.categoriesCollection = financesDb.Collection("categories")
+		singleton.accountsCollection = financesDb.Collection("accounts")
+		singleton.recurringRulesCollection = financesDb.Collection("recurringRules")
+		singleton.budgetsCollection = financesDb.Collection("budgets")
+		singleton.webhooksCollection = financesDb.Collection("webhooks")
+		singleton.deliveriesCollection = financesDb.Collection("webhookDeliveries")
+		singleton.auditCollection = financesDb.Collection("auditLog")
+
+		err = singleton.ensureIndexes(ctx)
+		if err != nil {
+			log.Printf("could not create indexes: %v\n", err)
+		}
 	}
 
 	return singleton, nil
 }
 
+func (db *DB) ensureConnected(ctx context.Context) error {
+	if db.IsDisconnected {
+		err := db.client.Connect(ctx)
+		if err != nil {
+			return err
+		}
+
+		db.IsDisconnected = false
+	}
+
+	return nil
+}
+
+func (db *DB) ensureIndexes(ctx context.Context) error {
+	_, err := db.transactionsCollection.Indexes().CreateMany(
+		ctx,
+		[]mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "originalId", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{Keys: bson.D{{Key: "date", Value: 1}}},
+			{Keys: bson.D{{Key: "value", Value: 1}}},
+			{Keys: bson.D{{Key: "subcategory", Value: 1}}},
+			{Keys: bson.D{{Key: "accountId", Value: 1}, {Key: "date", Value: 1}}},
+			{
+				Keys:    bson.D{{Key: "transferId", Value: 1}},
+				Options: options.Index().SetSparse(true),
+			},
+			{
+				Keys:    bson.D{{Key: "installmentGroupId", Value: 1}},
+				Options: options.Index().SetSparse(true),
+			},
+			{
+				Keys:    bson.D{{Key: "description", Value: "text"}},
+				Options: options.Index().SetDefaultLanguage("none"),
+			},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.budgetsCollection.Indexes().CreateOne(
+		ctx,
+		mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "scope", Value: 1},
+				{Key: "name", Value: 1},
+				{Key: "month", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.deliveriesCollection.Indexes().CreateMany(
+		ctx,
+		[]mongo.IndexModel{
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}}},
+			{Keys: bson.D{{Key: "webhookId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.auditCollection.Indexes().CreateOne(
+		ctx,
+		mongo.IndexModel{
+			Keys: bson.D{{Key: "transactionId", Value: 1}, {Key: "at", Value: 1}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	uniqueNames := []*mongo.Collection{
+		db.subcategoriesCollection,
+		db.categoriesCollection,
+		db.accountsCollection,
+	}
+
+	for _, collection := range uniqueNames {
+		_, err = collection.Indexes().CreateOne(
+			ctx,
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "name", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// StoreTransactions upserts the transactions keyed on their original task ID,
+// so storing the same tasks more than once never creates duplicates. A stored
+// transaction is only updated when the task was modified after it was stored.
 func (db *DB) StoreTransactions(transactions ...entities.Transaction) (
-	int,
+	models.StoreResult,
 	error,
 ) {
 	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
 	defer cancel()
 
-	if db.IsDisconnected {
-		err := db.client.Connect(ctx)
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return models.StoreResult{}, err
+	}
+
+	return db.storeTransactions(ctx, transactions...)
+}
+
+// StoreTransfer stores both sides of a transfer within a MongoDB transaction,
+// so a transfer is never left with a single account.
+func (db *DB) StoreTransfer(out, in entities.Transaction) (
+	models.StoreResult,
+	error,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return models.StoreResult{}, err
+	}
+
+	session, err := db.client.StartSession()
+	if err != nil {
+		return models.StoreResult{}, err
+	}
+
+	defer session.EndSession(ctx)
+
+	result, err := session.WithTransaction(
+		ctx,
+		func(sessionCtx mongo.SessionContext) (interface{}, error) {
+			return db.storeTransactions(sessionCtx, out, in)
+		},
+	)
+	if err != nil {
+		return models.StoreResult{}, err
+	}
+
+	return result.(models.StoreResult), nil
+}
+
+func (db *DB) storeTransactions(
+	ctx context.Context,
+	transactions ...entities.Transaction,
+) (models.StoreResult, error) {
+	var storeResult models.StoreResult
+
+	if len(transactions) == 0 {
+		return storeResult, nil
+	}
+
+	// Mongo keeps milliseconds, so a task fetched again with the same
+	// modification would otherwise always look newer than the stored one
+	transactions = append([]entities.Transaction(nil), transactions...)
+	for i := range transactions {
+		transactions[i].ModifiedAt = transactions[i].ModifiedAt.Truncate(time.Millisecond)
+	}
+
+	stored, err := db.storedByTaskID(ctx, transactions)
+	if err != nil {
+		return storeResult, err
+	}
+
+	var writes []mongo.WriteModel
+	for _, t := range transactions {
+		update, err := transactionUpdate(&t)
 		if err != nil {
-			return 0, err
+			return storeResult, err
 		}
 
-		db.IsDisconnected = false
+		writes = append(
+			writes,
+			mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"originalId": t.OriginalTaskID}).
+				SetUpdate(bson.M{"$setOnInsert": t}).
+				SetUpsert(true),
+			mongo.NewUpdateOneModel().
+				SetFilter(
+					bson.M{
+						"originalId": t.OriginalTaskID,
+						"modifiedAt": bson.M{"$lt": t.ModifiedAt},
+					},
+				).
+				SetUpdate(update),
+		)
 	}
 
-	var items []interface{}
-	for _, t := range transactions {
-		items = append(items, t)
+	result, err := db.transactionsCollection.BulkWrite(
+		ctx,
+		writes,
+		options.BulkWrite().SetOrdered(true),
+	)
+	if result != nil {
+		storeResult.New = int(result.UpsertedCount)
+		storeResult.Updated = int(result.ModifiedCount)
+	}
+
+	for _, t := range transactions[:storedCount(err, len(transactions))] {
+		storeResult.TaskIDs = append(storeResult.TaskIDs, t.OriginalTaskID)
 	}
 
-	result, err := db.transactionsCollection.InsertMany(ctx, items)
 	if err != nil {
-		return 0, err
+		return storeResult, err
+	}
+
+	for i, t := range transactions {
+		previous, found := stored[t.OriginalTaskID]
+
+		switch {
+		case result.UpsertedIDs[int64(2*i)] != nil:
+			storeResult.Created(&t)
+		case found && previous.ModifiedAt.Before(t.ModifiedAt):
+			storeResult.Replaced(previous)
+		}
 	}
 
-	return len(result.InsertedIDs), nil
+	storeResult.AlreadyIngested =
+		len(transactions) - storeResult.New - storeResult.Updated
+
+	return storeResult, nil
 }
 
-func (db *DB) GetAllTransactions() (*[]entities.Transaction, error) {
+// storedByTaskID returns the transactions already stored, keyed by their
+// original task ID.
+func (db *DB) storedByTaskID(
+	ctx context.Context,
+	transactions []entities.Transaction,
+) (map[string]entities.Transaction, error) {
+	taskIDs := make([]string, len(transactions))
+	for i, t := range transactions {
+		taskIDs[i] = t.OriginalTaskID
+	}
+
+	cursor, err := db.transactionsCollection.Find(
+		ctx,
+		bson.M{"originalId": bson.M{"$in": taskIDs}},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored []entities.Transaction
+
+	err = cursor.All(ctx, &stored)
+	if err != nil {
+		return nil, err
+	}
+
+	byTaskID := make(map[string]entities.Transaction, len(stored))
+	for _, t := range stored {
+		byTaskID[t.OriginalTaskID] = t
+	}
+
+	return byTaskID, nil
+}
+
+// storedCount tells how many of the transactions were stored by the ordered
+// bulk write that returned err, which stops at the first failed write. Each
+// transaction takes two writes.
+func storedCount(err error, transactions int) int {
+	if err == nil {
+		return transactions
+	}
+
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && len(bulkErr.WriteErrors) > 0 {
+		return bulkErr.WriteErrors[0].Index / 2
+	}
+
+	return 0
+}
+
+// fields left out of a stored transaction when empty
+var optionalFields = []string{
+	"direction",
+	"categorizedBy",
+	"account",
+	"accountId",
+	"transferId",
+	"transferSide",
+	"installmentGroupId",
+	"installment",
+	"installments",
+	"tags",
+	"currency",
+}
+
+// transactionUpdate returns the update that overwrites a stored transaction
+// with the fields of t that may change after it is first stored. The
+// optional fields t leaves empty are unset, as they are missing from the
+// marshalled transaction.
+func transactionUpdate(t *entities.Transaction) (bson.M, error) {
+	raw, err := bson.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields bson.M
+
+	err = bson.Unmarshal(raw, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(fields, "_id")
+	delete(fields, "originalId")
+	delete(fields, "createdAt")
+
+	update := bson.M{"$set": fields}
+
+	unset := bson.M{}
+	for _, field := range optionalFields {
+		if _, found := fields[field]; !found {
+			unset[field] = ""
+		}
+	}
+
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	return update, nil
+}
+
+// GetTransaction returns the transaction with the ID, even when it was
+// deleted.
+func (db *DB) GetTransaction(id primitive.ObjectID) (*entities.Transaction, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
 	defer cancel()
 
-	if db.IsDisconnected {
-		err := db.client.Connect(ctx)
-		if err != nil {
-			return nil, err
-		}
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-		db.IsDisconnected = false
+	var transaction entities.Transaction
+
+	err = db.transactionsCollection.FindOne(ctx, bson.M{"_id": id}).
+		Decode(&transaction)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &transaction, nil
+}
+
+// UpdateTransactions replaces the transactions within a MongoDB
+// transaction, so the sides of a transfer are always changed together. Each
+// one must have been last modified at the time lastModified has for its ID,
+// otherwise someone else changed it since it was read and nothing is
+// replaced, returning ErrModified.
+func (db *DB) UpdateTransactions(
+	transactions []entities.Transaction,
+	lastModified map[primitive.ObjectID]time.Time,
+) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(transactions) == 1 {
+		return db.replaceTransaction(ctx, &transactions[0], lastModified)
+	}
+
+	session, err := db.client.StartSession()
+	if err != nil {
+		return err
+	}
+
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(
+		ctx,
+		func(sessionCtx mongo.SessionContext) (interface{}, error) {
+			for i := range transactions {
+				err := db.replaceTransaction(sessionCtx, &transactions[i], lastModified)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			return nil, nil
+		},
+	)
+
+	return err
+}
+
+func (db *DB) replaceTransaction(
+	ctx context.Context,
+	transaction *entities.Transaction,
+	lastModified map[primitive.ObjectID]time.Time,
+) error {
+	result, err := db.transactionsCollection.ReplaceOne(
+		ctx,
+		bson.M{"_id": transaction.ID, "modifiedAt": lastModified[transaction.ID]},
+		transaction,
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount > 0 {
+		return nil
+	}
+
+	count, err := db.transactionsCollection.CountDocuments(
+		ctx,
+		bson.M{"_id": transaction.ID},
+	)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrNotFound
+	}
+
+	return models.ErrModified
+}
+
+func (db *DB) GetAllTransactions() (*[]entities.Transaction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	var transactions []entities.Transaction
 
-	cursor, err := db.transactionsCollection.Find(ctx, bson.D{})
+	cursor, err := db.transactionsCollection.Find(ctx, bson.M{"deletedAt": nil})
 	if err != nil {
 		return nil, err
 	}
@@ -152,16 +580,15 @@ func (db *DB) GetTransactionBySubcategory(subRegex string) (
 	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
 	defer cancel()
 
-	if db.IsDisconnected {
-		err := db.client.Connect(ctx)
-		if err != nil {
-			return nil, err
-		}
-
-		db.IsDisconnected = false
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	filter := bson.M{"subcategory": primitive.Regex{Pattern: subRegex}}
+	filter := bson.M{
+		"subcategory": primitive.Regex{Pattern: subRegex},
+		"deletedAt":   nil,
+	}
 
 	var transactions []entities.Transaction
 
@@ -189,3 +616,466 @@ func (db *DB) GetTransactionBySubcategory(subRegex string) (
 
 	return &transactions, nil
 }
+
+func (db *DB) QueryTransactions(query models.TransactionQuery) (
+	*models.TransactionPage,
+	error,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := queryFilter(&query)
+
+	total, err := db.transactionsCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	order := 1
+	if query.Descending {
+		order = -1
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: query.Sort, Value: order}, {Key: "_id", Value: order}}).
+		SetSkip(int64(query.Skip()))
+
+	if query.PageSize > 0 {
+		findOptions.SetLimit(int64(query.PageSize))
+	}
+
+	cursor, err := db.transactionsCollection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	transactions := make([]entities.Transaction, 0)
+	for cursor.Next(ctx) {
+		currTransaction := entities.Transaction{}
+		if err = cursor.Decode(&currTransaction); err != nil {
+			log.Println(err.Error())
+		}
+
+		transactions = append(transactions, currTransaction)
+	}
+
+	totals, err := db.totals(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf(
+		"found %v of %v transactions matching the query",
+		len(transactions),
+		total,
+	)
+
+	return &models.TransactionPage{
+		Transactions: transactions,
+		Total:        total,
+		Totals:       *totals,
+		Page:         query.Page,
+		PageSize:     query.PageSize,
+	}, nil
+}
+
+// totals adds up the income and expenses of every transaction matching the
+// filter.
+func (db *DB) totals(ctx context.Context, filter bson.M) (*models.Totals, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      nil,
+			"income":   bson.M{"$sum": bson.M{"$cond": bson.A{isIncome, "$value", 0}}},
+			"expenses": bson.M{"$sum": bson.M{"$cond": bson.A{isExpense, "$value", 0}}},
+		}}},
+	}
+
+	cursor, err := db.transactionsCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	var results []models.Totals
+
+	err = cursor.All(ctx, &results)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := &models.Totals{}
+	if len(results) > 0 {
+		totals = &results[0]
+	}
+
+	totals.Net = totals.Income - totals.Expenses
+	totals.Round()
+
+	return totals, nil
+}
+
+// isIncome and isExpense are aggregation expressions telling the direction
+// of a transaction. Transactions without a direction are expenses.
+var (
+	isIncome  = bson.M{"$eq": bson.A{"$direction", entities.DirectionIncome}}
+	isExpense = bson.M{"$in": bson.A{
+		bson.M{"$ifNull": bson.A{"$direction", entities.DirectionExpense}},
+		bson.A{entities.DirectionExpense, ""},
+	}}
+)
+
+func queryFilter(query *models.TransactionQuery) bson.M {
+	filter := bson.M{}
+
+	if !query.IncludeDeleted {
+		filter["deletedAt"] = nil
+	}
+
+	date := bson.M{}
+	if !query.From.IsZero() {
+		date["$gte"] = query.From
+	}
+	if !query.To.IsZero() {
+		date["$lt"] = query.To
+	}
+	if len(date) > 0 {
+		filter["date"] = date
+	}
+
+	value := bson.M{}
+	if query.MinValue != nil {
+		value["$gte"] = *query.MinValue
+	}
+	if query.MaxValue != nil {
+		value["$lte"] = *query.MaxValue
+	}
+	if len(value) > 0 {
+		filter["value"] = value
+	}
+
+	if query.Description != "" {
+		filter["$text"] = bson.M{"$search": query.Description}
+	}
+
+	if query.Subcategory != "" {
+		filter["subcategory"] = primitive.Regex{Pattern: query.Subcategory}
+	}
+
+	if !query.AccountID.IsZero() {
+		filter["accountId"] = query.AccountID
+	}
+
+	if !query.InstallmentGroupID.IsZero() {
+		filter["installmentGroupId"] = query.InstallmentGroupID
+	}
+
+	if !query.TransferID.IsZero() {
+		filter["transferId"] = query.TransferID
+	}
+
+	switch query.Direction {
+	case "":
+	case entities.DirectionExpense:
+		filter["direction"] = bson.M{"$in": bson.A{entities.DirectionExpense, nil}}
+	default:
+		filter["direction"] = query.Direction
+	}
+
+	return filter
+}
+
+// SummarizeTransactions aggregates the transactions dated between from
+// (inclusive) and to (exclusive). Zero times leave that side open.
+func (db *DB) SummarizeTransactions(from, to time.Time) (
+	*models.Summary,
+	error,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := queryFilter(&models.TransactionQuery{From: from, To: to})
+
+	filter["direction"] = bson.M{"$ne": entities.DirectionTransfer}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$facet", Value: bson.M{
+			"total": summaryGroupStages(nil),
+			"byMonth": append(
+				summaryGroupStages(
+					bson.M{
+						"$dateToString": bson.M{
+							"format": "%Y-%m",
+							"date":   "$date",
+						},
+					},
+				),
+				bson.M{"$sort": bson.M{"_id": 1}},
+			),
+			"bySubcategory": append(
+				summaryGroupStages("$subcategory"),
+				bson.M{"$sort": bson.D{{Key: "total", Value: -1}, {Key: "_id", Value: 1}}},
+			),
+			"byCategory": append(
+				summaryGroupStages("$category"),
+				bson.M{"$sort": bson.D{{Key: "total", Value: -1}, {Key: "_id", Value: 1}}},
+			),
+		}}},
+	}
+
+	cursor, err := db.transactionsCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	var facets []struct {
+		Total         []models.SummaryGroup `bson:"total"`
+		ByMonth       []models.SummaryGroup `bson:"byMonth"`
+		BySubcategory []models.SummaryGroup `bson:"bySubcategory"`
+		ByCategory    []models.SummaryGroup `bson:"byCategory"`
+	}
+
+	err = cursor.All(ctx, &facets)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &models.Summary{
+		ByMonth:       make([]models.SummaryGroup, 0),
+		BySubcategory: make([]models.SummaryGroup, 0),
+		ByCategory:    make([]models.SummaryGroup, 0),
+	}
+
+	if len(facets) > 0 {
+		if len(facets[0].Total) > 0 {
+			summary.Total = facets[0].Total[0]
+		}
+
+		summary.ByMonth = append(summary.ByMonth, facets[0].ByMonth...)
+		summary.BySubcategory = append(summary.BySubcategory, facets[0].BySubcategory...)
+		summary.ByCategory = append(summary.ByCategory, facets[0].ByCategory...)
+	}
+
+	return summary, nil
+}
+
+// summaryGroupStages groups the transactions by the key, keeping the totals
+// of expenses apart from the income.
+func summaryGroupStages(key interface{}) bson.A {
+	return bson.A{
+		bson.M{
+			"$group": bson.M{
+				"_id":     key,
+				"total":   bson.M{"$sum": bson.M{"$cond": bson.A{isExpense, "$value", 0}}},
+				"count":   bson.M{"$sum": bson.M{"$cond": bson.A{isExpense, 1, 0}}},
+				"average": bson.M{"$avg": bson.M{"$cond": bson.A{isExpense, "$value", nil}}},
+				"income":  bson.M{"$sum": bson.M{"$cond": bson.A{isIncome, "$value", 0}}},
+			},
+		},
+		bson.M{
+			"$addFields": bson.M{
+				"average":  bson.M{"$ifNull": bson.A{"$average", 0}},
+				"expenses": "$total",
+				"net":      bson.M{"$subtract": bson.A{"$income", "$total"}},
+			},
+		},
+	}
+}
+
+// RenameSubcategory moves every transaction of the given subcategories to
+// the new one, returning how many transactions were changed. The changed
+// transactions are modified now.
+func (db *DB) RenameSubcategory(names []string, newName string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := db.transactionsCollection.UpdateMany(
+		ctx,
+		bson.M{"subcategory": bson.M{"$in": names, "$ne": newName}},
+		bson.M{
+			"$set": bson.M{
+				"subcategory": newName,
+				"modifiedAt":  time.Now().UTC().Truncate(time.Millisecond),
+			},
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.ModifiedCount, nil
+}
+
+// SetCategory sets the category of every transaction of the given
+// subcategories, returning how many transactions were changed. The changed
+// transactions are modified now.
+func (db *DB) SetCategory(subcategories []string, category string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := db.transactionsCollection.UpdateMany(
+		ctx,
+		bson.M{
+			"subcategory": bson.M{"$in": subcategories},
+			"category":    bson.M{"$ne": category},
+		},
+		bson.M{
+			"$set": bson.M{
+				"category":   category,
+				"modifiedAt": time.Now().UTC().Truncate(time.Millisecond),
+			},
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.ModifiedCount, nil
+}
+
+// LinkAccount points the transactions registered under the account name, or
+// under its previous name, to the account, returning how many transactions
+// were changed. The changed transactions are modified now.
+func (db *DB) LinkAccount(account *entities.Account, previousName string) (
+	int64,
+	error,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	names := []string{account.Name}
+	if previousName != "" {
+		names = append(names, previousName)
+	}
+
+	result, err := db.transactionsCollection.UpdateMany(
+		ctx,
+		bson.M{"$and": bson.A{
+			bson.M{"$or": bson.A{
+				bson.M{"accountId": account.ID},
+				bson.M{"account": bson.M{"$in": names}},
+			}},
+			bson.M{"$or": bson.A{
+				bson.M{"accountId": bson.M{"$ne": account.ID}},
+				bson.M{"account": bson.M{"$ne": account.Name}},
+			}},
+		}},
+		bson.M{
+			"$set": bson.M{
+				"account":    account.Name,
+				"accountId":  account.ID,
+				"modifiedAt": time.Now().UTC().Truncate(time.Millisecond),
+			},
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.ModifiedCount, nil
+}
+
+// AccountMovements sums how much each account changed per period of the
+// interval, for the transactions matching the query.
+func (db *DB) AccountMovements(
+	query models.TransactionQuery,
+	interval string,
+) ([]models.Movement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	format := "%Y-%m"
+	if interval == models.IntervalDay {
+		format = "%Y-%m-%d"
+	}
+
+	filter := queryFilter(&query)
+	if _, ok := filter["accountId"]; !ok {
+		filter["accountId"] = bson.M{"$exists": true}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"accountId": "$accountId",
+				"period": bson.M{
+					"$dateToString": bson.M{"format": format, "date": "$date"},
+				},
+			},
+			"change": bson.M{"$sum": bson.M{"$switch": bson.M{
+				"branches": bson.A{
+					bson.M{"case": isIncome, "then": "$value"},
+					bson.M{"case": isExpense, "then": bson.M{"$multiply": bson.A{"$value", -1}}},
+					bson.M{
+						"case": bson.M{"$eq": bson.A{"$transferSide", entities.TransferIn}},
+						"then": "$value",
+					},
+					bson.M{
+						"case": bson.M{"$eq": bson.A{"$transferSide", entities.TransferOut}},
+						"then": bson.M{"$multiply": bson.A{"$value", -1}},
+					},
+				},
+				"default": 0,
+			}}},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":       0,
+			"accountId": "$_id.accountId",
+			"period":    "$_id.period",
+			"change":    1,
+		}}},
+	}
+
+	cursor, err := db.transactionsCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	movements := make([]models.Movement, 0)
+
+	err = cursor.All(ctx, &movements)
+	if err != nil {
+		return nil, err
+	}
+
+	return movements, nil
+}
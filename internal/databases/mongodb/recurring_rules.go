@@ -0,0 +1,139 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+func (db *DB) GetAllRecurringRules() (*[]entities.RecurringRule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := db.recurringRulesCollection.Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]entities.RecurringRule, 0)
+
+	err = cursor.All(ctx, &rules)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rules, nil
+}
+
+func (db *DB) GetRecurringRule(id primitive.ObjectID) (*entities.RecurringRule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var rule entities.RecurringRule
+
+	err = db.recurringRulesCollection.FindOne(ctx, bson.M{"_id": id}).
+		Decode(&rule)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+func (db *DB) StoreRecurringRule(rule *entities.RecurringRule) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.recurringRulesCollection.InsertOne(ctx, rule)
+	return err
+}
+
+func (db *DB) UpdateRecurringRule(rule *entities.RecurringRule) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.recurringRulesCollection.ReplaceOne(
+		ctx,
+		bson.M{"_id": rule.ID},
+		rule,
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) DeleteRecurringRule(id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.recurringRulesCollection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SetMaterializedUntil records up to when the occurrences of a rule were
+// stored as transactions. It never moves back, so a slow run cannot undo a
+// later one.
+func (db *DB) SetMaterializedUntil(id primitive.ObjectID, until time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.recurringRulesCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "materializedUntil": bson.M{"$lt": until}},
+		bson.M{"$set": bson.M{"materializedUntil": until}},
+	)
+	return err
+}
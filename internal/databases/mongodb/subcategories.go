@@ -0,0 +1,123 @@
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+	"github.com/jbonadiman/finances-api/internal/models"
+)
+
+var ErrNotFound = models.ErrNotFound
+
+func (db *DB) GetAllSubcategories() (*[]entities.Subcategory, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := db.subcategoriesCollection.Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+
+	subcategories := make([]entities.Subcategory, 0)
+
+	err = cursor.All(ctx, &subcategories)
+	if err != nil {
+		return nil, err
+	}
+
+	return &subcategories, nil
+}
+
+func (db *DB) GetSubcategory(id primitive.ObjectID) (
+	*entities.Subcategory,
+	error,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var subcategory entities.Subcategory
+
+	err = db.subcategoriesCollection.FindOne(ctx, bson.M{"_id": id}).
+		Decode(&subcategory)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &subcategory, nil
+}
+
+func (db *DB) StoreSubcategory(subcategory *entities.Subcategory) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.subcategoriesCollection.InsertOne(ctx, subcategory)
+	return err
+}
+
+func (db *DB) UpdateSubcategory(subcategory *entities.Subcategory) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.subcategoriesCollection.ReplaceOne(
+		ctx,
+		bson.M{"_id": subcategory.ID},
+		subcategory,
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) DeleteSubcategories(ids ...primitive.ObjectID) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := db.subcategoriesCollection.DeleteMany(
+		ctx,
+		bson.M{"_id": bson.M{"$in": ids}},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,227 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+func (db *DB) GetAllWebhooks() (*[]entities.Webhook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := db.webhooksCollection.Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks := make([]entities.Webhook, 0)
+
+	err = cursor.All(ctx, &webhooks)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webhooks, nil
+}
+
+func (db *DB) GetWebhook(id primitive.ObjectID) (*entities.Webhook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var webhook entities.Webhook
+
+	err = db.webhooksCollection.FindOne(ctx, bson.M{"_id": id}).
+		Decode(&webhook)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+func (db *DB) StoreWebhook(webhook *entities.Webhook) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.webhooksCollection.InsertOne(ctx, webhook)
+	return err
+}
+
+func (db *DB) UpdateWebhook(webhook *entities.Webhook) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.webhooksCollection.ReplaceOne(
+		ctx,
+		bson.M{"_id": webhook.ID},
+		webhook,
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) DeleteWebhook(id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.webhooksCollection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) StoreDeliveries(deliveries ...entities.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	documents := make([]interface{}, len(deliveries))
+	for i := range deliveries {
+		documents[i] = deliveries[i]
+	}
+
+	_, err = db.deliveriesCollection.InsertMany(ctx, documents)
+	return err
+}
+
+func (db *DB) UpdateDelivery(delivery *entities.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.deliveriesCollection.ReplaceOne(
+		ctx,
+		bson.M{"_id": delivery.ID},
+		delivery,
+	)
+	return err
+}
+
+// GetDeliveries returns the latest deliveries, optionally only the ones of a
+// webhook or with a status.
+func (db *DB) GetDeliveries(
+	webhookID primitive.ObjectID,
+	status string,
+	limit int,
+) (*[]entities.WebhookDelivery, error) {
+	filter := bson.M{}
+	if !webhookID.IsZero() {
+		filter["webhookId"] = webhookID
+	}
+
+	if status != "" {
+		filter["status"] = status
+	}
+
+	return db.findDeliveries(
+		filter,
+		options.Find().
+			SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+			SetLimit(int64(limit)),
+	)
+}
+
+// GetDueDeliveries returns the pending deliveries whose next attempt is due
+// by now, oldest first.
+func (db *DB) GetDueDeliveries(now time.Time, limit int) (
+	*[]entities.WebhookDelivery,
+	error,
+) {
+	return db.findDeliveries(
+		bson.M{
+			"status":        entities.DeliveryPending,
+			"nextAttemptAt": bson.M{"$lte": now},
+		},
+		options.Find().
+			SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}}).
+			SetLimit(int64(limit)),
+	)
+}
+
+func (db *DB) findDeliveries(filter bson.M, findOptions *options.FindOptions) (
+	*[]entities.WebhookDelivery,
+	error,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err := db.ensureConnected(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := db.deliveriesCollection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]entities.WebhookDelivery, 0)
+
+	err = cursor.All(ctx, &deliveries)
+	if err != nil {
+		return nil, err
+	}
+
+	return &deliveries, nil
+}
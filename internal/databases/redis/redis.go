@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"golang.org/x/oauth2"
 
+	"github.com/jbonadiman/finances-api/internal/entities"
 	"github.com/jbonadiman/finances-api/internal/environment"
 	"github.com/jbonadiman/finances-api/internal/utils"
 )
@@ -18,7 +20,14 @@ type DB struct {
 	client *redis.Client
 }
 
-const TimeOut = 3 * time.Second
+const (
+	TimeOut = 3 * time.Second
+
+	invalidTaskExpiration = 90 * 24 * time.Hour
+	budgetAlertExpiration = 62 * 24 * time.Hour
+
+	subcategoriesImportedKey = "import:subcategories"
+)
 
 var singleton *DB
 
@@ -130,7 +139,7 @@ func (db *DB) ParseSubcategory(subcategory string) (string, error) {
 	defer cancel()
 
 	var parsedSubcategory, err = db.client.Get(
-		ctx, fmt.Sprintf("subcategory:%v", subcategory)).Result()
+		ctx, subcategoryKey(subcategory)).Result()
 
 	if err != nil {
 		log.Printf("error parsing subcategory %q\n", subcategory)
@@ -139,3 +148,198 @@ func (db *DB) ParseSubcategory(subcategory string) (string, error) {
 
 	return parsedSubcategory, nil
 }
+
+// GetSubcategoryAliases returns the subcategory each cached alias points
+// to, keyed by alias.
+func (db *DB) GetSubcategoryAliases() (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	var keys []string
+
+	iter := db.client.Scan(ctx, 0, subcategoryKey("*"), 0).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	aliases := make(map[string]string, len(keys))
+	if len(keys) == 0 {
+		return aliases, nil
+	}
+
+	values, err := db.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, value := range values {
+		name, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		aliases[strings.TrimPrefix(keys[i], subcategoryKey(""))] = name
+	}
+
+	return aliases, nil
+}
+
+// SubcategoriesImported tells whether the cached aliases were already
+// imported into the subcategories collection.
+func (db *DB) SubcategoriesImported() (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	count, err := db.client.Exists(ctx, subcategoriesImportedKey).Result()
+	return count > 0, err
+}
+
+func (db *DB) MarkSubcategoriesImported() error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	return db.client.Set(
+		ctx,
+		subcategoriesImportedKey,
+		time.Now().UTC().Format(time.RFC3339),
+		0,
+	).Err()
+}
+
+// SyncSubcategories writes the cached "subcategory:<alias>" keys of the
+// given subcategories. Only when removeStale is set are the aliases missing
+// from them removed, which is safe once the cache was imported into the
+// subcategories collection, otherwise they would be lost.
+func (db *DB) SyncSubcategories(
+	subcategories []entities.Subcategory,
+	removeStale bool,
+) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	aliases := make(map[string]interface{})
+	for _, s := range subcategories {
+		aliases[subcategoryKey(s.Name)] = s.Name
+
+		for _, alias := range s.Aliases {
+			aliases[subcategoryKey(alias)] = s.Name
+		}
+	}
+
+	var staleKeys []string
+
+	if removeStale {
+		iter := db.client.Scan(ctx, 0, subcategoryKey("*"), 0).Iterator()
+		for iter.Next(ctx) {
+			if _, found := aliases[iter.Val()]; !found {
+				staleKeys = append(staleKeys, iter.Val())
+			}
+		}
+
+		if err := iter.Err(); err != nil {
+			return err
+		}
+	}
+
+	pipe := db.client.TxPipeline()
+
+	if len(staleKeys) > 0 {
+		pipe.Del(ctx, staleKeys...)
+	}
+
+	if len(aliases) > 0 {
+		pipe.MSet(ctx, aliases)
+	}
+
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Printf(
+		"cached %v subcategory aliases, removed %v stale ones\n",
+		len(aliases),
+		len(staleKeys),
+	)
+
+	return nil
+}
+
+func subcategoryKey(alias string) string {
+	return fmt.Sprintf("subcategory:%v", alias)
+}
+
+// StoreInvalidTask remembers that a task could not be parsed, along with
+// its last modification after it was flagged as invalid.
+func (db *DB) StoreInvalidTask(taskID string, modifiedAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	return db.client.Set(
+		ctx,
+		invalidTaskKey(taskID),
+		modifiedAt.Format(time.RFC3339Nano),
+		invalidTaskExpiration,
+	).Err()
+}
+
+// GetInvalidTask returns when a task was last modified after being flagged
+// as invalid, or a zero time if it is not flagged.
+func (db *DB) GetInvalidTask(taskID string) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	value, err := db.client.Get(ctx, invalidTaskKey(taskID)).Result()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+func (db *DB) ClearInvalidTask(taskID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	return db.client.Del(ctx, invalidTaskKey(taskID)).Err()
+}
+
+func invalidTaskKey(taskID string) string {
+	return fmt.Sprintf("invalid-task:%v", taskID)
+}
+
+// ClaimBudgetAlert records that the threshold of a budget was reached,
+// returning false when it was already recorded. Budgets belong to a single
+// month, so each threshold is claimed once per month.
+func (db *DB) ClaimBudgetAlert(budgetID string, threshold int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	return db.client.SetNX(
+		ctx,
+		budgetAlertKey(budgetID, threshold),
+		time.Now().UTC().Format(time.RFC3339),
+		budgetAlertExpiration,
+	).Result()
+}
+
+// ReleaseBudgetAlert forgets a claimed threshold, so the alert is raised
+// again on the next ingestion.
+func (db *DB) ReleaseBudgetAlert(budgetID string, threshold int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	return db.client.Del(ctx, budgetAlertKey(budgetID, threshold)).Err()
+}
+
+func budgetAlertKey(budgetID string, threshold int) string {
+	return fmt.Sprintf("budget-alert:%v:%v", budgetID, threshold)
+}
@@ -0,0 +1,198 @@
+package databases
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/databases/memory"
+	"github.com/jbonadiman/finances-api/internal/databases/mongodb"
+	"github.com/jbonadiman/finances-api/internal/databases/sqlite"
+	"github.com/jbonadiman/finances-api/internal/entities"
+	"github.com/jbonadiman/finances-api/internal/environment"
+	"github.com/jbonadiman/finances-api/internal/models"
+)
+
+var ErrMongoRequired = errors.New(
+	"this feature requires the mongodb storage backend",
+)
+
+var ErrNotFound = mongodb.ErrNotFound
+
+var ErrModified = models.ErrModified
+
+type TransactionRepository interface {
+	StoreTransactions(transactions ...entities.Transaction) (
+		models.StoreResult,
+		error,
+	)
+	StoreTransfer(out, in entities.Transaction) (models.StoreResult, error)
+	GetTransaction(id primitive.ObjectID) (*entities.Transaction, error)
+	UpdateTransactions(
+		transactions []entities.Transaction,
+		lastModified map[primitive.ObjectID]time.Time,
+	) error
+	GetAllTransactions() (*[]entities.Transaction, error)
+	GetTransactionBySubcategory(subRegex string) (
+		*[]entities.Transaction,
+		error,
+	)
+	QueryTransactions(query models.TransactionQuery) (
+		*models.TransactionPage,
+		error,
+	)
+	SummarizeTransactions(from, to time.Time) (*models.Summary, error)
+	RenameSubcategory(names []string, newName string) (int64, error)
+	SetCategory(subcategories []string, category string) (int64, error)
+	LinkAccount(account *entities.Account, previousName string) (int64, error)
+	AccountMovements(query models.TransactionQuery, interval string) (
+		[]models.Movement,
+		error,
+	)
+}
+
+type SubcategoryRepository interface {
+	GetAllSubcategories() (*[]entities.Subcategory, error)
+	GetSubcategory(id primitive.ObjectID) (*entities.Subcategory, error)
+	StoreSubcategory(subcategory *entities.Subcategory) error
+	UpdateSubcategory(subcategory *entities.Subcategory) error
+	DeleteSubcategories(ids ...primitive.ObjectID) (int64, error)
+}
+
+type CategoryRepository interface {
+	GetAllCategories() (*[]entities.Category, error)
+	GetCategory(id primitive.ObjectID) (*entities.Category, error)
+	StoreCategory(category *entities.Category) error
+	UpdateCategory(category *entities.Category) error
+	DeleteCategory(id primitive.ObjectID) error
+}
+
+type AccountRepository interface {
+	GetAllAccounts() (*[]entities.Account, error)
+	GetAccount(id primitive.ObjectID) (*entities.Account, error)
+	StoreAccount(account *entities.Account) error
+	UpdateAccount(account *entities.Account) error
+	DeleteAccount(id primitive.ObjectID) error
+}
+
+type RecurringRuleRepository interface {
+	GetAllRecurringRules() (*[]entities.RecurringRule, error)
+	GetRecurringRule(id primitive.ObjectID) (*entities.RecurringRule, error)
+	StoreRecurringRule(rule *entities.RecurringRule) error
+	UpdateRecurringRule(rule *entities.RecurringRule) error
+	DeleteRecurringRule(id primitive.ObjectID) error
+	SetMaterializedUntil(id primitive.ObjectID, until time.Time) error
+}
+
+type BudgetRepository interface {
+	GetBudgets(months ...string) (*[]entities.Budget, error)
+	GetBudget(id primitive.ObjectID) (*entities.Budget, error)
+	SetBudget(budget *entities.Budget) error
+	DeleteBudget(id primitive.ObjectID) error
+}
+
+type WebhookRepository interface {
+	GetAllWebhooks() (*[]entities.Webhook, error)
+	GetWebhook(id primitive.ObjectID) (*entities.Webhook, error)
+	StoreWebhook(webhook *entities.Webhook) error
+	UpdateWebhook(webhook *entities.Webhook) error
+	DeleteWebhook(id primitive.ObjectID) error
+	StoreDeliveries(deliveries ...entities.WebhookDelivery) error
+	UpdateDelivery(delivery *entities.WebhookDelivery) error
+	GetDeliveries(webhookID primitive.ObjectID, status string, limit int) (
+		*[]entities.WebhookDelivery,
+		error,
+	)
+	GetDueDeliveries(now time.Time, limit int) (*[]entities.WebhookDelivery, error)
+}
+
+// AuditRepository appends to the audit log of the transactions, which is
+// never changed once written.
+type AuditRepository interface {
+	StoreAuditEntries(entries ...entities.AuditEntry) error
+	GetTransactionHistory(id primitive.ObjectID) (*[]entities.AuditEntry, error)
+}
+
+// GetAuditRepository returns the repository of the audit log, which is only
+// kept in MongoDB.
+func GetAuditRepository() (AuditRepository, error) {
+	if environment.StorageBackend != environment.MongoBackend {
+		return nil, ErrMongoRequired
+	}
+
+	return mongodb.GetDB()
+}
+
+// GetWebhookRepository returns the repository of webhooks and their
+// deliveries, which are only kept in MongoDB.
+func GetWebhookRepository() (WebhookRepository, error) {
+	if environment.StorageBackend != environment.MongoBackend {
+		return nil, ErrMongoRequired
+	}
+
+	return mongodb.GetDB()
+}
+
+// GetBudgetRepository returns the repository of budgets, which are only kept
+// in MongoDB.
+func GetBudgetRepository() (BudgetRepository, error) {
+	if environment.StorageBackend != environment.MongoBackend {
+		return nil, ErrMongoRequired
+	}
+
+	return mongodb.GetDB()
+}
+
+// GetRecurringRuleRepository returns the repository of recurring rules,
+// which are only kept in MongoDB.
+func GetRecurringRuleRepository() (RecurringRuleRepository, error) {
+	if environment.StorageBackend != environment.MongoBackend {
+		return nil, ErrMongoRequired
+	}
+
+	return mongodb.GetDB()
+}
+
+// GetAccountRepository returns the repository of accounts, which are only
+// kept in MongoDB.
+func GetAccountRepository() (AccountRepository, error) {
+	if environment.StorageBackend != environment.MongoBackend {
+		return nil, ErrMongoRequired
+	}
+
+	return mongodb.GetDB()
+}
+
+// GetCategoryRepository returns the repository of categories, which are
+// only kept in MongoDB.
+func GetCategoryRepository() (CategoryRepository, error) {
+	if environment.StorageBackend != environment.MongoBackend {
+		return nil, ErrMongoRequired
+	}
+
+	return mongodb.GetDB()
+}
+
+// GetSubcategoryRepository returns the repository of subcategories, which
+// are only kept in MongoDB.
+func GetSubcategoryRepository() (SubcategoryRepository, error) {
+	if environment.StorageBackend != environment.MongoBackend {
+		return nil, ErrMongoRequired
+	}
+
+	return mongodb.GetDB()
+}
+
+// GetTransactionRepository returns the repository of the storage backend
+// selected by the STORAGE_BACKEND environment variable.
+func GetTransactionRepository() (TransactionRepository, error) {
+	switch environment.StorageBackend {
+	case environment.SQLiteBackend:
+		return sqlite.GetDB(environment.SQLitePath)
+	case environment.MemoryBackend:
+		return memory.GetDB()
+	default:
+		return mongodb.GetDB()
+	}
+}
@@ -0,0 +1,860 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+	"github.com/jbonadiman/finances-api/internal/models"
+	"github.com/jbonadiman/finances-api/internal/utils"
+)
+
+type DB struct {
+	utils.Connection
+	client *sql.DB
+}
+
+const (
+	TimeOut = 5 * time.Second
+
+	driverName = "sqlite3_finances"
+)
+
+// migrations are applied in order on startup, the index of the last one
+// applied is kept in the user_version pragma. Only append to this list.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS transactions (
+		id          TEXT PRIMARY KEY,
+		original_id TEXT NOT NULL UNIQUE,
+		date        TIMESTAMP NOT NULL,
+		created_at  TIMESTAMP NOT NULL,
+		modified_at TIMESTAMP NOT NULL,
+		description TEXT NOT NULL,
+		value       REAL NOT NULL,
+		category    TEXT NOT NULL DEFAULT '',
+		subcategory TEXT NOT NULL DEFAULT '',
+		account     TEXT NOT NULL DEFAULT '',
+		tags        TEXT NOT NULL DEFAULT '[]',
+		currency    TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE INDEX IF NOT EXISTS transactions_date ON transactions (date);
+	CREATE INDEX IF NOT EXISTS transactions_value ON transactions (value);
+	CREATE INDEX IF NOT EXISTS transactions_subcategory ON transactions (subcategory);`,
+
+	`ALTER TABLE transactions ADD COLUMN categorized_by TEXT NOT NULL DEFAULT '';`,
+
+	`ALTER TABLE transactions ADD COLUMN account_id TEXT NOT NULL DEFAULT '';
+
+	CREATE INDEX IF NOT EXISTS transactions_account_date ON transactions (account_id, date);`,
+
+	`ALTER TABLE transactions ADD COLUMN direction TEXT NOT NULL DEFAULT '';`,
+
+	`ALTER TABLE transactions ADD COLUMN transfer_id TEXT NOT NULL DEFAULT '';
+	ALTER TABLE transactions ADD COLUMN transfer_side TEXT NOT NULL DEFAULT '';`,
+
+	`ALTER TABLE transactions ADD COLUMN installment_group_id TEXT NOT NULL DEFAULT '';
+	ALTER TABLE transactions ADD COLUMN installment INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE transactions ADD COLUMN installments INTEGER NOT NULL DEFAULT 0;
+
+	CREATE INDEX IF NOT EXISTS transactions_installment_group ON transactions (installment_group_id);`,
+
+	`ALTER TABLE transactions ADD COLUMN deleted_at TIMESTAMP;`,
+}
+
+var transactionColumns = []string{
+	"id",
+	"original_id",
+	"date",
+	"created_at",
+	"modified_at",
+	"description",
+	"value",
+	"direction",
+	"category",
+	"subcategory",
+	"categorized_by",
+	"account",
+	"account_id",
+	"transfer_id",
+	"transfer_side",
+	"installment_group_id",
+	"installment",
+	"installments",
+	"tags",
+	"currency",
+	"deleted_at",
+}
+
+// columns that are never changed once a transaction is stored, deletions
+// are only changed by UpdateTransactions and survive ingesting the task again
+var immutableColumns = map[string]bool{
+	"id":          true,
+	"original_id": true,
+	"created_at":  true,
+	"deleted_at":  true,
+}
+
+var sortColumns = map[string]string{
+	models.SortByDate:        "date",
+	models.SortByValue:       "value",
+	models.SortByDescription: "description",
+	models.SortBySubcategory: "subcategory",
+	models.SortByCreatedAt:   "created_at",
+}
+
+var singleton *DB
+
+func init() {
+	sql.Register(
+		driverName,
+		&sqlite3.SQLiteDriver{
+			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+				return conn.RegisterFunc("regexp", matchRegex, true)
+			},
+		},
+	)
+}
+
+func matchRegex(pattern, value string) (bool, error) {
+	return regexp.MatchString(pattern, value)
+}
+
+// GetDB returns the database at the path, opened on the first call and
+// shared by every later one.
+func GetDB(path string) (*DB, error) {
+	if singleton == nil {
+		db, err := NewDB(path)
+		if err != nil {
+			return nil, err
+		}
+
+		singleton = db
+	}
+
+	return singleton, nil
+}
+
+// NewDB opens the database at the path, applying the migrations it misses.
+func NewDB(path string) (*DB, error) {
+	db := &DB{
+		Connection: utils.Connection{
+			Host:             path,
+			ConnectionString: path,
+		},
+	}
+
+	client, err := sql.Open(driverName, db.ConnectionString)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	err = migrate(ctx, client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	db.client = client
+
+	return db, nil
+}
+
+func migrate(ctx context.Context, client *sql.DB) error {
+	var version int
+
+	err := client.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&version)
+	if err != nil {
+		return err
+	}
+
+	for ; version < len(migrations); version++ {
+		log.Printf("applying sqlite migration %v...\n", version+1)
+
+		_, err = client.ExecContext(ctx, migrations[version])
+		if err != nil {
+			return err
+		}
+
+		_, err = client.ExecContext(
+			ctx,
+			fmt.Sprintf(`PRAGMA user_version = %v`, version+1),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) StoreTransactions(transactions ...entities.Transaction) (
+	models.StoreResult,
+	error,
+) {
+	var storeResult models.StoreResult
+
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	tx, err := db.client.BeginTx(ctx, nil)
+	if err != nil {
+		return storeResult, err
+	}
+
+	upsert := upsertStatement()
+
+	for _, t := range transactions {
+		previous, err := storedTransaction(ctx, tx, t.OriginalTaskID)
+
+		switch {
+		case err == sql.ErrNoRows:
+			storeResult.New++
+			storeResult.Created(&t)
+		case err != nil:
+			tx.Rollback()
+			return models.StoreResult{}, err
+		case previous.ModifiedAt.Before(t.ModifiedAt):
+			storeResult.Updated++
+			storeResult.Replaced(*previous)
+		default:
+			storeResult.AlreadyIngested++
+			storeResult.TaskIDs = append(storeResult.TaskIDs, t.OriginalTaskID)
+			continue
+		}
+
+		values, err := transactionValues(&t)
+		if err != nil {
+			tx.Rollback()
+			return models.StoreResult{}, err
+		}
+
+		_, err = tx.ExecContext(ctx, upsert, values...)
+		if err != nil {
+			tx.Rollback()
+			return models.StoreResult{}, err
+		}
+
+		storeResult.TaskIDs = append(storeResult.TaskIDs, t.OriginalTaskID)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return models.StoreResult{}, err
+	}
+
+	return storeResult, nil
+}
+
+// storedTransaction returns the transaction of the task within the SQL
+// transaction, or sql.ErrNoRows when it was not stored yet.
+func storedTransaction(
+	ctx context.Context,
+	tx *sql.Tx,
+	taskID string,
+) (*entities.Transaction, error) {
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT `+selectColumns()+` FROM transactions WHERE original_id = ?`,
+		taskID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, sql.ErrNoRows
+	}
+
+	return scanTransaction(rows)
+}
+
+func upsertStatement() string {
+	var updates []string
+	for _, column := range transactionColumns {
+		if !immutableColumns[column] {
+			updates = append(updates, column+" = excluded."+column)
+		}
+	}
+
+	return `INSERT INTO transactions (` + selectColumns() + `)
+		VALUES (` + placeholders(len(transactionColumns)) + `)
+		ON CONFLICT (original_id) DO UPDATE SET ` + strings.Join(updates, ", ")
+}
+
+func selectColumns() string {
+	return strings.Join(transactionColumns, ", ")
+}
+
+func placeholders(count int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", count), ", ")
+}
+
+// StoreTransfer stores both sides of a transfer in the same SQL
+// transaction, so a transfer is never left with a single account.
+func (db *DB) StoreTransfer(out, in entities.Transaction) (
+	models.StoreResult,
+	error,
+) {
+	return db.StoreTransactions(out, in)
+}
+
+// GetTransaction returns the transaction with the ID, even when it was
+// deleted.
+func (db *DB) GetTransaction(id primitive.ObjectID) (*entities.Transaction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	transactions, err := db.queryTransactions(
+		ctx,
+		`SELECT `+selectColumns()+` FROM transactions WHERE id = ?`,
+		id.Hex(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(transactions) == 0 {
+		return nil, models.ErrNotFound
+	}
+
+	return &transactions[0], nil
+}
+
+// UpdateTransactions replaces the transactions in the same SQL
+// transaction, so the sides of a transfer are always changed together. Each
+// one must have been last modified at the time lastModified has for its ID,
+// otherwise someone else changed it since it was read and nothing is
+// replaced, returning ErrModified.
+func (db *DB) UpdateTransactions(
+	transactions []entities.Transaction,
+	lastModified map[primitive.ObjectID]time.Time,
+) error {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	tx, err := db.client.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for i := range transactions {
+		err = replaceTransaction(ctx, tx, &transactions[i], lastModified[transactions[i].ID])
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func replaceTransaction(
+	ctx context.Context,
+	tx *sql.Tx,
+	transaction *entities.Transaction,
+	modifiedAt time.Time,
+) error {
+	var stored time.Time
+
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT modified_at FROM transactions WHERE id = ?`,
+		transaction.ID.Hex(),
+	).Scan(&stored)
+
+	switch {
+	case err == sql.ErrNoRows:
+		return models.ErrNotFound
+	case err != nil:
+		return err
+	case !stored.Equal(modifiedAt):
+		return models.ErrModified
+	}
+
+	values, err := transactionValues(transaction)
+	if err != nil {
+		return err
+	}
+
+	var updates []string
+	var args []interface{}
+	for i, column := range transactionColumns {
+		if column == "id" || column == "original_id" || column == "created_at" {
+			continue
+		}
+
+		updates = append(updates, column+" = ?")
+		args = append(args, values[i])
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE transactions SET `+strings.Join(updates, ", ")+` WHERE id = ?`,
+		append(args, transaction.ID.Hex())...,
+	)
+
+	return err
+}
+
+func (db *DB) GetAllTransactions() (*[]entities.Transaction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	transactions, err := db.queryTransactions(
+		ctx,
+		`SELECT `+selectColumns()+` FROM transactions WHERE deleted_at IS NULL`,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf(
+		"found %v transactions",
+		len(transactions),
+	)
+
+	return &transactions, nil
+}
+
+func (db *DB) GetTransactionBySubcategory(subRegex string) (
+	*[]entities.Transaction,
+	error,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	transactions, err := db.queryTransactions(
+		ctx,
+		`SELECT `+selectColumns()+` FROM transactions
+		WHERE subcategory REGEXP ? AND deleted_at IS NULL`,
+		subRegex,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf(
+		"found %v transactions with the subcategory %q pattern",
+		len(transactions),
+		subRegex,
+	)
+
+	return &transactions, nil
+}
+
+func (db *DB) QueryTransactions(query models.TransactionQuery) (
+	*models.TransactionPage,
+	error,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	where, args := queryFilter(&query)
+
+	var total int64
+	var totals models.Totals
+	err := db.client.QueryRowContext(
+		ctx,
+		`SELECT
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN direction = 'income' THEN value ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN direction IN ('expense', '') THEN value ELSE 0 END), 0)
+		FROM transactions`+where,
+		args...,
+	).Scan(&total, &totals.Income, &totals.Expenses)
+	if err != nil {
+		return nil, err
+	}
+
+	totals.Net = totals.Income - totals.Expenses
+	totals.Round()
+
+	column, ok := sortColumns[query.Sort]
+	if !ok {
+		column = sortColumns[models.SortByDate]
+	}
+
+	order := "ASC"
+	if query.Descending {
+		order = "DESC"
+	}
+
+	statement := `SELECT ` + selectColumns() + ` FROM transactions` + where +
+		fmt.Sprintf(` ORDER BY %v %v, id %v`, column, order, order)
+
+	if query.PageSize > 0 {
+		statement += ` LIMIT ? OFFSET ?`
+		args = append(args, query.PageSize, query.Skip())
+	}
+
+	transactions, err := db.queryTransactions(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if transactions == nil {
+		transactions = make([]entities.Transaction, 0)
+	}
+
+	log.Printf(
+		"found %v of %v transactions matching the query",
+		len(transactions),
+		total,
+	)
+
+	return &models.TransactionPage{
+		Transactions: transactions,
+		Total:        total,
+		Totals:       totals,
+		Page:         query.Page,
+		PageSize:     query.PageSize,
+	}, nil
+}
+
+func queryFilter(query *models.TransactionQuery) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if !query.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+
+	if !query.From.IsZero() {
+		conditions = append(conditions, "date >= ?")
+		args = append(args, query.From.UTC())
+	}
+
+	if !query.To.IsZero() {
+		conditions = append(conditions, "date < ?")
+		args = append(args, query.To.UTC())
+	}
+
+	if query.MinValue != nil {
+		conditions = append(conditions, "value >= ?")
+		args = append(args, *query.MinValue)
+	}
+
+	if query.MaxValue != nil {
+		conditions = append(conditions, "value <= ?")
+		args = append(args, *query.MaxValue)
+	}
+
+	if query.Subcategory != "" {
+		conditions = append(conditions, "subcategory REGEXP ?")
+		args = append(args, query.Subcategory)
+	}
+
+	if !query.AccountID.IsZero() {
+		conditions = append(conditions, "account_id = ?")
+		args = append(args, query.AccountID.Hex())
+	}
+
+	if !query.InstallmentGroupID.IsZero() {
+		conditions = append(conditions, "installment_group_id = ?")
+		args = append(args, query.InstallmentGroupID.Hex())
+	}
+
+	if !query.TransferID.IsZero() {
+		conditions = append(conditions, "transfer_id = ?")
+		args = append(args, query.TransferID.Hex())
+	}
+
+	switch query.Direction {
+	case "":
+	case entities.DirectionExpense:
+		conditions = append(conditions, "direction IN (?, '')")
+		args = append(args, query.Direction)
+	default:
+		conditions = append(conditions, "direction = ?")
+		args = append(args, query.Direction)
+	}
+
+	if terms := strings.Fields(query.Description); len(terms) > 0 {
+		var termConditions []string
+		for _, term := range terms {
+			termConditions = append(termConditions, "description LIKE ?")
+			args = append(args, "%"+term+"%")
+		}
+
+		conditions = append(
+			conditions,
+			"("+strings.Join(termConditions, " OR ")+")",
+		)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (db *DB) queryTransactions(
+	ctx context.Context,
+	query string,
+	args ...interface{},
+) ([]entities.Transaction, error) {
+	rows, err := db.client.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var transactions []entities.Transaction
+	for rows.Next() {
+		currTransaction, err := scanTransaction(rows)
+		if err != nil {
+			log.Println(err.Error())
+			continue
+		}
+
+		transactions = append(transactions, *currTransaction)
+	}
+
+	return transactions, rows.Err()
+}
+
+// transactionValues returns the values of the transaction in the same order
+// as transactionColumns.
+func transactionValues(t *entities.Transaction) ([]interface{}, error) {
+	tags, err := json.Marshal(t.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	return []interface{}{
+		t.ID.Hex(),
+		t.OriginalTaskID,
+		t.Date.UTC(),
+		t.CreatedAt.UTC(),
+		t.ModifiedAt.UTC(),
+		t.Description,
+		t.Cost,
+		t.Direction,
+		t.Category,
+		t.Subcategory,
+		t.CategorizedBy,
+		t.Account,
+		optionalID(t.AccountID),
+		optionalID(t.TransferID),
+		t.TransferSide,
+		optionalID(t.InstallmentGroupID),
+		t.Installment,
+		t.Installments,
+		string(tags),
+		t.Currency,
+		t.DeletedAt,
+	}, nil
+}
+
+// scanTransaction reads a row selected with the columns in the same order
+// as transactionColumns.
+func scanTransaction(rows *sql.Rows) (*entities.Transaction, error) {
+	var t entities.Transaction
+	var id, accountID, transferID, installmentGroupID, tags string
+	var deletedAt sql.NullTime
+
+	err := rows.Scan(
+		&id,
+		&t.OriginalTaskID,
+		&t.Date,
+		&t.CreatedAt,
+		&t.ModifiedAt,
+		&t.Description,
+		&t.Cost,
+		&t.Direction,
+		&t.Category,
+		&t.Subcategory,
+		&t.CategorizedBy,
+		&t.Account,
+		&accountID,
+		&transferID,
+		&t.TransferSide,
+		&installmentGroupID,
+		&t.Installment,
+		&t.Installments,
+		&tags,
+		&t.Currency,
+		&deletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	t.ID, err = primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if accountID != "" {
+		t.AccountID, err = primitive.ObjectIDFromHex(accountID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if transferID != "" {
+		t.TransferID, err = primitive.ObjectIDFromHex(transferID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if installmentGroupID != "" {
+		t.InstallmentGroupID, err = primitive.ObjectIDFromHex(installmentGroupID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = json.Unmarshal([]byte(tags), &t.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	if deletedAt.Valid {
+		t.DeletedAt = &deletedAt.Time
+	}
+
+	return &t, nil
+}
+
+// optionalID stores zero IDs as empty strings, so they can be told apart
+// from references to other documents.
+func optionalID(id primitive.ObjectID) string {
+	if id.IsZero() {
+		return ""
+	}
+
+	return id.Hex()
+}
+
+func (db *DB) SummarizeTransactions(from, to time.Time) (
+	*models.Summary,
+	error,
+) {
+	page, err := db.QueryTransactions(models.TransactionQuery{From: from, To: to})
+	if err != nil {
+		return nil, err
+	}
+
+	return models.Summarize(page.Transactions), nil
+}
+
+func (db *DB) RenameSubcategory(names []string, newName string) (int64, error) {
+	if len(names) == 0 {
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	args := []interface{}{newName, time.Now().UTC().Truncate(time.Millisecond), newName}
+	for _, name := range names {
+		args = append(args, name)
+	}
+
+	result, err := db.client.ExecContext(
+		ctx,
+		`UPDATE transactions SET subcategory = ?, modified_at = ?
+		WHERE subcategory <> ? AND subcategory IN (`+placeholders(len(names))+`)`,
+		args...,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (db *DB) SetCategory(subcategories []string, category string) (int64, error) {
+	if len(subcategories) == 0 {
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	args := []interface{}{category, time.Now().UTC().Truncate(time.Millisecond), category}
+	for _, subcategory := range subcategories {
+		args = append(args, subcategory)
+	}
+
+	result, err := db.client.ExecContext(
+		ctx,
+		`UPDATE transactions SET category = ?, modified_at = ?
+		WHERE category <> ? AND subcategory IN (`+placeholders(len(subcategories))+`)`,
+		args...,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (db *DB) LinkAccount(account *entities.Account, previousName string) (
+	int64,
+	error,
+) {
+	if previousName == "" {
+		previousName = account.Name
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), TimeOut)
+	defer cancel()
+
+	result, err := db.client.ExecContext(
+		ctx,
+		`UPDATE transactions SET account = ?, account_id = ?, modified_at = ?
+		WHERE (account_id = ? OR account IN (?, ?))
+		AND (account != ? OR account_id != ?)`,
+		account.Name,
+		account.ID.Hex(),
+		time.Now().UTC().Truncate(time.Millisecond),
+		account.ID.Hex(),
+		account.Name,
+		previousName,
+		account.Name,
+		account.ID.Hex(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (db *DB) AccountMovements(
+	query models.TransactionQuery,
+	interval string,
+) ([]models.Movement, error) {
+	query.Page, query.PageSize = 0, 0
+
+	page, err := db.QueryTransactions(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.Movements(page.Transactions, interval), nil
+}
@@ -0,0 +1,318 @@
+package sqlite
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+	"github.com/jbonadiman/finances-api/internal/models"
+)
+
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	db, err := NewDB(filepath.Join(t.TempDir(), "finances.db"))
+	if err != nil {
+		t.Fatalf("could not open the database: %v", err)
+	}
+
+	t.Cleanup(func() { db.client.Close() })
+
+	return db
+}
+
+func newTransaction(taskID string, description string, modifiedAt time.Time) entities.Transaction {
+	return entities.Transaction{
+		ID:             primitive.NewObjectID(),
+		OriginalTaskID: taskID,
+		Date:           modifiedAt,
+		CreatedAt:      modifiedAt,
+		ModifiedAt:     modifiedAt,
+		Description:    description,
+		Cost:           10,
+		Subcategory:    "mercado",
+	}
+}
+
+func TestStoreTransactionsUpsertsByTaskID(t *testing.T) {
+	db := newTestDB(t)
+	modifiedAt := time.Date(2021, 2, 15, 10, 0, 0, 0, time.UTC)
+
+	first := newTransaction("task-1", "pizza", modifiedAt)
+	newer := newTransaction("task-1", "pizza delivery", modifiedAt.Add(time.Minute))
+	older := newTransaction("task-1", "old pizza", modifiedAt.Add(-time.Minute))
+	taskIDs := []string{"task-1"}
+
+	steps := []struct {
+		name        string
+		transaction entities.Transaction
+		want        models.StoreResult
+		description string
+	}{
+		{
+			name:        "new",
+			transaction: first,
+			want: models.StoreResult{
+				New:     1,
+				Changes: []models.StoredTransaction{{TaskID: "task-1", ID: first.ID, New: true}},
+				TaskIDs: taskIDs,
+			},
+			description: "pizza",
+		},
+		{name: "same", transaction: first, want: models.StoreResult{AlreadyIngested: 1, TaskIDs: taskIDs}, description: "pizza"},
+		{
+			name:        "newer",
+			transaction: newer,
+			want: models.StoreResult{
+				Updated: 1,
+				Changes: []models.StoredTransaction{{TaskID: "task-1", ID: first.ID, Previous: &first}},
+				TaskIDs: taskIDs,
+			},
+			description: "pizza delivery",
+		},
+		{name: "older", transaction: older, want: models.StoreResult{AlreadyIngested: 1, TaskIDs: taskIDs}, description: "pizza delivery"},
+	}
+
+	for _, step := range steps {
+		result, err := db.StoreTransactions(step.transaction)
+		if err != nil {
+			t.Fatalf("%v: StoreTransactions returned an error: %v", step.name, err)
+		}
+
+		if !reflect.DeepEqual(result, step.want) {
+			t.Errorf("%v: got %+v, want %+v", step.name, result, step.want)
+		}
+
+		all, err := db.GetAllTransactions()
+		if err != nil {
+			t.Fatalf("%v: GetAllTransactions returned an error: %v", step.name, err)
+		}
+
+		if len(*all) != 1 {
+			t.Fatalf("%v: got %v transactions, want 1", step.name, len(*all))
+		}
+
+		stored := (*all)[0]
+		if stored.ID != first.ID || stored.Description != step.description {
+			t.Errorf(
+				"%v: stored %v %q, want %v %q",
+				step.name,
+				stored.ID.Hex(),
+				stored.Description,
+				first.ID.Hex(),
+				step.description,
+			)
+		}
+	}
+}
+
+func TestQueryTransactions(t *testing.T) {
+	db := newTestDB(t)
+	february := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	for i, description := range []string{"pizza delivery", "coffee beans", "rice", "concert tickets"} {
+		transaction := newTransaction(
+			"task-"+description,
+			description,
+			february.AddDate(0, 0, i*10),
+		)
+		transaction.Cost = []float64{5, 20, 50, 100}[i]
+		transaction.Subcategory = []string{"delivery", "mercado", "mercado", "lazer"}[i]
+
+		if _, err := db.StoreTransactions(transaction); err != nil {
+			t.Fatalf("StoreTransactions returned an error: %v", err)
+		}
+	}
+
+	twenty, fifty := 20.0, 50.0
+
+	tests := []struct {
+		name  string
+		query models.TransactionQuery
+		want  []string
+		total int64
+	}{
+		{
+			name:  "every match",
+			query: models.TransactionQuery{Sort: models.SortByDate},
+			want:  []string{"pizza delivery", "coffee beans", "rice", "concert tickets"},
+			total: 4,
+		},
+		{
+			name: "date range",
+			query: models.TransactionQuery{
+				From: february.AddDate(0, 0, 10),
+				To:   february.AddDate(0, 0, 30),
+				Sort: models.SortByDate,
+			},
+			want:  []string{"coffee beans", "rice"},
+			total: 2,
+		},
+		{
+			name:  "value range",
+			query: models.TransactionQuery{MinValue: &twenty, MaxValue: &fifty, Sort: models.SortByDate},
+			want:  []string{"coffee beans", "rice"},
+			total: 2,
+		},
+		{
+			name:  "description",
+			query: models.TransactionQuery{Description: "PIZZA beans", Sort: models.SortByDate},
+			want:  []string{"pizza delivery", "coffee beans"},
+			total: 2,
+		},
+		{
+			name:  "subcategory",
+			query: models.TransactionQuery{Subcategory: "^merc", Sort: models.SortByDate},
+			want:  []string{"coffee beans", "rice"},
+			total: 2,
+		},
+		{
+			name:  "by value descending",
+			query: models.TransactionQuery{Sort: models.SortByValue, Descending: true},
+			want:  []string{"concert tickets", "rice", "coffee beans", "pizza delivery"},
+			total: 4,
+		},
+		{
+			name:  "by description",
+			query: models.TransactionQuery{Sort: models.SortByDescription},
+			want:  []string{"coffee beans", "concert tickets", "pizza delivery", "rice"},
+			total: 4,
+		},
+		{
+			name:  "last page",
+			query: models.TransactionQuery{Sort: models.SortByDate, Page: 2, PageSize: 3},
+			want:  []string{"concert tickets"},
+			total: 4,
+		},
+		{
+			name:  "past the last page",
+			query: models.TransactionQuery{Sort: models.SortByDate, Page: 3, PageSize: 2},
+			want:  []string{},
+			total: 4,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page, err := db.QueryTransactions(test.query)
+			if err != nil {
+				t.Fatalf("QueryTransactions returned an error: %v", err)
+			}
+
+			got := make([]string, 0, len(page.Transactions))
+			for _, transaction := range page.Transactions {
+				got = append(got, transaction.Description)
+			}
+
+			if !reflect.DeepEqual(got, test.want) || page.Total != test.total {
+				t.Errorf("got %q of %v, want %q of %v", got, page.Total, test.want, test.total)
+			}
+		})
+	}
+}
+
+func userVersion(t *testing.T, db *DB) int {
+	t.Helper()
+
+	var version int
+
+	err := db.client.QueryRow(`PRAGMA user_version`).Scan(&version)
+	if err != nil {
+		t.Fatalf("could not read the schema version: %v", err)
+	}
+
+	return version
+}
+
+func TestMigrationsApplyOnlyMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "finances.db")
+
+	client, err := sql.Open(driverName, path)
+	if err != nil {
+		t.Fatalf("could not open the database: %v", err)
+	}
+
+	_, err = client.Exec(migrations[0] + `PRAGMA user_version = 1;`)
+	client.Close()
+	if err != nil {
+		t.Fatalf("could not apply the first migration: %v", err)
+	}
+
+	// opening it twice would fail if a migration was applied again, as
+	// columns can only be added once
+	for i := 0; i < 2; i++ {
+		db, err := NewDB(path)
+		if err != nil {
+			t.Fatalf("open %v: could not migrate the database: %v", i+1, err)
+		}
+
+		version := userVersion(t, db)
+		db.client.Close()
+
+		if version != len(migrations) {
+			t.Errorf("open %v: schema version %v, want %v", i+1, version, len(migrations))
+		}
+	}
+}
+
+func TestBulkChangesModifyTransactions(t *testing.T) {
+	db := newTestDB(t)
+	modifiedAt := time.Date(2021, 2, 15, 10, 0, 0, 0, time.UTC)
+
+	changed := newTransaction("task-1", "arroz", modifiedAt)
+	changed.Account = "itau"
+	kept := newTransaction("task-2", "pao", modifiedAt)
+	kept.Subcategory = "padaria"
+
+	_, err := db.StoreTransactions(changed, kept)
+	if err != nil {
+		t.Fatalf("StoreTransactions returned an error: %v", err)
+	}
+
+	account := &entities.Account{ID: primitive.NewObjectID(), Name: "itau"}
+	changes := []struct {
+		name   string
+		change func() (int64, error)
+		want   int64
+	}{
+		{name: "rename", change: func() (int64, error) { return db.RenameSubcategory([]string{"mercado"}, "feira") }, want: 1},
+		{name: "rename again", change: func() (int64, error) { return db.RenameSubcategory([]string{"feira"}, "feira") }, want: 0},
+		{name: "category", change: func() (int64, error) { return db.SetCategory([]string{"feira"}, "comida") }, want: 1},
+		{name: "account", change: func() (int64, error) { return db.LinkAccount(account, "") }, want: 1},
+		{name: "account again", change: func() (int64, error) { return db.LinkAccount(account, "") }, want: 0},
+	}
+
+	for _, change := range changes {
+		got, err := change.change()
+		if err != nil {
+			t.Fatalf("%v returned an error: %v", change.name, err)
+		}
+
+		if got != change.want {
+			t.Errorf("%v changed %v transactions, want %v", change.name, got, change.want)
+		}
+	}
+
+	all, err := db.GetAllTransactions()
+	if err != nil {
+		t.Fatalf("GetAllTransactions returned an error: %v", err)
+	}
+
+	for _, transaction := range *all {
+		wasChanged := transaction.OriginalTaskID == changed.OriginalTaskID
+		if transaction.ModifiedAt.After(modifiedAt) != wasChanged {
+			t.Errorf("%v was modified at %v, changed: %v", transaction.OriginalTaskID, transaction.ModifiedAt, wasChanged)
+		}
+
+		if wasChanged && (transaction.Subcategory != "feira" ||
+			transaction.Category != "comida" ||
+			transaction.AccountID != account.ID) {
+			t.Errorf("changed transaction = %+v", transaction)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package entities
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Types of account
+const (
+	AccountChecking = "checking"
+	AccountSavings  = "savings"
+	AccountCredit   = "credit"
+	AccountCash     = "cash"
+)
+
+var AccountTypes = []string{
+	AccountChecking,
+	AccountSavings,
+	AccountCredit,
+	AccountCash,
+}
+
+type Account struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id"`
+	Name           string             `json:"name" bson:"name"`
+	Type           string             `json:"type" bson:"type"`
+	Currency       string             `json:"currency" bson:"currency"`
+	OpeningBalance float64            `json:"openingBalance" bson:"openingBalance"`
+}
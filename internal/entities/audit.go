@@ -0,0 +1,45 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Actions recorded in the audit log
+const (
+	AuditInsert       = "insert"
+	AuditUpdate       = "update"
+	AuditRecategorize = "recategorize"
+	AuditDelete       = "delete"
+	AuditRestore      = "restore"
+)
+
+// Sources of the changes recorded in the audit log. Imports are the commands
+// run straight against the storage, such as backfills.
+const (
+	AuditSourceToDo      = "todo"
+	AuditSourceAPI       = "api"
+	AuditSourceRecurring = "recurring"
+	AuditSourceImport    = "import"
+)
+
+// AuditEntry records a change to a transaction: who made it, when, from where
+// and the fields it changed. Entries are only ever appended.
+type AuditEntry struct {
+	ID            primitive.ObjectID `json:"id" bson:"_id"`
+	TransactionID primitive.ObjectID `json:"transactionId" bson:"transactionId"`
+	Action        string             `json:"action" bson:"action"`
+	Source        string             `json:"source" bson:"source"`
+	User          string             `json:"user,omitempty" bson:"user,omitempty"`
+	At            time.Time          `json:"at" bson:"at"`
+	Changes       []AuditChange      `json:"changes" bson:"changes"`
+}
+
+// AuditChange is the value of a field before and after a change, nil when the
+// field was not set.
+type AuditChange struct {
+	Field  string      `json:"field" bson:"field"`
+	Before interface{} `json:"before" bson:"before"`
+	After  interface{} `json:"after" bson:"after"`
+}
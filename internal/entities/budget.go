@@ -0,0 +1,23 @@
+package entities
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Scopes of a budget
+const (
+	BudgetSubcategory = "subcategory"
+	BudgetCategory    = "category"
+)
+
+// Budget limits the spending of a subcategory or category in a month. With
+// rollover, what was left unspent of the previous month's budget is added to
+// the limit.
+type Budget struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id"`
+	Scope    string             `json:"scope" bson:"scope"`
+	Name     string             `json:"name" bson:"name"`
+	Month    string             `json:"month" bson:"month"`
+	Limit    float64            `json:"limit" bson:"limit"`
+	Rollover bool               `json:"rollover" bson:"rollover"`
+}
@@ -0,0 +1,25 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RecurringRule describes a transaction repeated on a schedule, such as rent
+// or a subscription. Its occurrences are stored as transactions up to
+// MaterializedUntil.
+type RecurringRule struct {
+	ID                primitive.ObjectID `json:"id" bson:"_id"`
+	Description       string             `json:"description" bson:"description"`
+	Amount            float64            `json:"amount" bson:"amount"`
+	Direction         string             `json:"direction" bson:"direction"`
+	Subcategory       string             `json:"subcategory" bson:"subcategory"`
+	Account           string             `json:"account,omitempty" bson:"account,omitempty"`
+	AccountID         primitive.ObjectID `json:"accountId,omitempty" bson:"accountId,omitempty"`
+	Schedule          string             `json:"schedule" bson:"schedule"`
+	Start             time.Time          `json:"start" bson:"start"`
+	End               *time.Time         `json:"end,omitempty" bson:"end,omitempty"`
+	MaterializedUntil time.Time          `json:"materializedUntil" bson:"materializedUntil"`
+	ModifiedAt        time.Time          `json:"modifiedAt" bson:"modifiedAt"`
+}
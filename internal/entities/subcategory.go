@@ -5,7 +5,9 @@ import (
 )
 
 type Subcategory struct {
-	ID       primitive.ObjectID `json:"id" bson:"_id"`
-	Keywords []string           `json:"keywords" bson:"keywords"`
-	Name     string             `json:"name" bson:"name"`
+	ID         primitive.ObjectID `json:"id" bson:"_id"`
+	Keywords   []string           `json:"keywords" bson:"keywords"`
+	Name       string             `json:"name" bson:"name"`
+	Aliases    []string           `json:"aliases" bson:"aliases"`
+	CategoryID primitive.ObjectID `json:"categoryId,omitempty" bson:"categoryId,omitempty"`
 }
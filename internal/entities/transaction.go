@@ -6,14 +6,96 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Directions of a transaction. Transactions stored before directions existed
+// have none and are expenses.
+const (
+	DirectionExpense  = "expense"
+	DirectionIncome   = "income"
+	DirectionTransfer = "transfer"
+)
+
+// Sides of a transfer, each stored as its own transaction
+const (
+	TransferOut = "out"
+	TransferIn  = "in"
+)
+
+var Directions = []string{
+	DirectionExpense,
+	DirectionIncome,
+	DirectionTransfer,
+}
+
 type Transaction struct {
-	ID             primitive.ObjectID `json:"id" bson:"_id"`
-	OriginalTaskID string             `json:"originalId" bson:"originalId"`
-	Date           time.Time          `json:"date" bson:"date"`
-	CreatedAt      time.Time          `json:"createdAt" bson:"createdAt"`
-	ModifiedAt     time.Time          `json:"modifiedAt" bson:"modifiedAt"`
-	Description    string             `json:"description" bson:"description"`
-	Cost           float64            `json:"value" bson:"value"`
-	Category       string             `json:"category" bson:"category"`
-	Subcategory    string             `json:"subcategory" bson:"subcategory"`
+	ID                 primitive.ObjectID `json:"id" bson:"_id"`
+	OriginalTaskID     string             `json:"originalId" bson:"originalId"`
+	Date               time.Time          `json:"date" bson:"date"`
+	CreatedAt          time.Time          `json:"createdAt" bson:"createdAt"`
+	ModifiedAt         time.Time          `json:"modifiedAt" bson:"modifiedAt"`
+	Description        string             `json:"description" bson:"description"`
+	Cost               float64            `json:"value" bson:"value"`
+	Direction          string             `json:"direction" bson:"direction,omitempty"`
+	Category           string             `json:"category" bson:"category"`
+	Subcategory        string             `json:"subcategory" bson:"subcategory"`
+	CategorizedBy      string             `json:"categorizedBy,omitempty" bson:"categorizedBy,omitempty"`
+	Account            string             `json:"account,omitempty" bson:"account,omitempty"`
+	AccountID          primitive.ObjectID `json:"accountId,omitempty" bson:"accountId,omitempty"`
+	TransferID         primitive.ObjectID `json:"transferId,omitempty" bson:"transferId,omitempty"`
+	TransferSide       string             `json:"transferSide,omitempty" bson:"transferSide,omitempty"`
+	InstallmentGroupID primitive.ObjectID `json:"installmentGroupId,omitempty" bson:"installmentGroupId,omitempty"`
+	Installment        int                `json:"installment,omitempty" bson:"installment,omitempty"`
+	Installments       int                `json:"installments,omitempty" bson:"installments,omitempty"`
+	Tags               []string           `json:"tags,omitempty" bson:"tags,omitempty"`
+	Currency           string             `json:"currency,omitempty" bson:"currency,omitempty"`
+	DeletedAt          *time.Time         `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+}
+
+// IsIncome reports whether the transaction brought money in.
+func (t *Transaction) IsIncome() bool {
+	return t.Direction == DirectionIncome
+}
+
+// IsExpense reports whether the transaction took money out. Transfers are
+// neither income nor expenses.
+func (t *Transaction) IsExpense() bool {
+	return t.Direction == DirectionExpense || t.Direction == ""
+}
+
+// IsDeleted reports whether the transaction was soft deleted, which hides
+// it from everything but being restored.
+func (t *Transaction) IsDeleted() bool {
+	return t.DeletedAt != nil
+}
+
+// IsTransfer reports whether the transaction is one side of a transfer
+// between accounts.
+func (t *Transaction) IsTransfer() bool {
+	return t.Direction == DirectionTransfer
+}
+
+// SignedValue is the value of the transaction, positive for income and
+// negative for expenses. Transfers only move money around, so they are zero.
+func (t *Transaction) SignedValue() float64 {
+	switch {
+	case t.IsIncome():
+		return t.Cost
+	case t.IsExpense():
+		return -t.Cost
+	default:
+		return 0
+	}
+}
+
+// BalanceChange is how much the transaction changes the balance of its
+// account. Unlike SignedValue, it takes transfers into account.
+func (t *Transaction) BalanceChange() float64 {
+	if !t.IsTransfer() {
+		return t.SignedValue()
+	}
+
+	if t.TransferSide == TransferIn {
+		return t.Cost
+	}
+
+	return -t.Cost
 }
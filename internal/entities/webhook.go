@@ -0,0 +1,75 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Events sent to webhooks
+const (
+	EventTransactionCreated = "transaction.created"
+	EventTransactionUpdated = "transaction.updated"
+	EventTransactionDeleted = "transaction.deleted"
+	EventIngestionFinished  = "ingestion.finished"
+)
+
+var Events = []string{
+	EventTransactionCreated,
+	EventTransactionUpdated,
+	EventTransactionDeleted,
+	EventIngestionFinished,
+}
+
+// Statuses of a webhook delivery
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is a URL that receives the events it subscribed to, signed with
+// its secret. A webhook without events receives every event.
+type Webhook struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	URL       string             `json:"url" bson:"url"`
+	Secret    string             `json:"secret,omitempty" bson:"secret"`
+	Events    []string           `json:"events" bson:"events"`
+	Active    bool               `json:"active" bson:"active"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// Subscribes reports whether the webhook receives the event.
+func (w *Webhook) Subscribes(event string) bool {
+	if !w.Active {
+		return false
+	}
+
+	if len(w.Events) == 0 {
+		return true
+	}
+
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WebhookDelivery is an event sent, or to be sent, to a webhook, along with
+// the outcome of the last attempt.
+type WebhookDelivery struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id"`
+	WebhookID      primitive.ObjectID `json:"webhookId" bson:"webhookId"`
+	Event          string             `json:"event" bson:"event"`
+	Payload        string             `json:"payload" bson:"payload"`
+	Status         string             `json:"status" bson:"status"`
+	Attempts       int                `json:"attempts" bson:"attempts"`
+	ResponseStatus int                `json:"responseStatus,omitempty" bson:"responseStatus,omitempty"`
+	LastError      string             `json:"lastError,omitempty" bson:"lastError,omitempty"`
+	CreatedAt      time.Time          `json:"createdAt" bson:"createdAt"`
+	NextAttemptAt  *time.Time         `json:"nextAttemptAt,omitempty" bson:"nextAttemptAt,omitempty"`
+	DeliveredAt    *time.Time         `json:"deliveredAt,omitempty" bson:"deliveredAt,omitempty"`
+}
@@ -1,10 +1,14 @@
 package environment
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -17,8 +21,34 @@ const (
 	MongoHostKey             = "MONGO_HOST"
 	MongoUserKey             = "MONGO_USER"
 	MongoPasswordKey         = "MONGO_SECRET"
+	StorageBackendKey        = "STORAGE_BACKEND"
+	SQLitePathKey            = "SQLITE_PATH"
 
 	TaskListIDKey    = "TASK_LIST_ID"
+	TaskListsKey     = "TASK_LISTS"
+	TasksPageSizeKey = "TASKS_PAGE_SIZE"
+	TasksMaxPagesKey = "TASKS_MAX_PAGES"
+
+	RecurringIntervalKey = "RECURRING_INTERVAL_MINUTES"
+
+	BudgetAlertListIDKey     = "BUDGET_ALERT_LIST_ID"
+	BudgetAlertThresholdsKey = "BUDGET_ALERT_THRESHOLDS"
+)
+
+const (
+	MongoBackend  = "mongodb"
+	SQLiteBackend = "sqlite"
+	MemoryBackend = "memory"
+)
+
+const (
+	defaultSQLitePath    = "finances.db"
+	defaultTasksPageSize = 100
+	defaultTasksMaxPages = 10
+
+	defaultRecurringInterval = 60
+
+	defaultBudgetAlertThresholds = "80,100"
 )
 
 var (
@@ -39,16 +69,45 @@ var (
 	MongoUser     string
 )
 
+var (
+	StorageBackend string
+	SQLitePath     string
+)
+
 var (
 	TaskListID    string
+	TaskLists     []TaskList
+	TasksPageSize int
+	TasksMaxPages int
 )
 
+// RecurringInterval is how many minutes the server waits between runs of the
+// recurring rules scheduler
+var RecurringInterval int
+
+var (
+	// BudgetAlertListID is the To Do list budget alerts are created in,
+	// apart from the ingested lists. Alerts are turned off when it is empty.
+	BudgetAlertListID string
+
+	// BudgetAlertThresholds are the percentages of a budget that raise an
+	// alert, in ascending order.
+	BudgetAlertThresholds []int
+)
+
+// TaskList is a To Do list whose tasks are ingested, every transaction
+// parsed from it belongs to its account.
+type TaskList struct {
+	ID      string `json:"id"`
+	Account string `json:"account"`
+}
+
 func init() {
 	unsetVarList := make([]string, 0)
 
 	unsetVarList = append(unsetVarList, loadMicrosoftVars()...)
 	unsetVarList = append(unsetVarList, loadLambdaStoreVars()...)
-	unsetVarList = append(unsetVarList, loadMongoDBVars()...)
+	unsetVarList = append(unsetVarList, loadStorageVars()...)
 
 	if len(unsetVarList) > 0 {
 		for _, err := range unsetVarList {
@@ -60,7 +119,40 @@ func init() {
 
 	var err error
 
-	TaskListID, err = loadVar(TaskListIDKey)
+	TaskLists, err = loadTaskLists()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	TasksPageSize, err = loadIntVar(TasksPageSizeKey, defaultTasksPageSize)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	TasksMaxPages, err = loadIntVar(TasksMaxPagesKey, defaultTasksMaxPages)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	RecurringInterval, err = loadIntVar(RecurringIntervalKey, defaultRecurringInterval)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	BudgetAlertListID = loadOptionalVar(BudgetAlertListIDKey, "")
+	for _, list := range TaskLists {
+		if list.ID == BudgetAlertListID {
+			log.Fatalf(
+				"%q environment variable must not be one of the ingested lists\n",
+				BudgetAlertListIDKey,
+			)
+		}
+	}
+
+	BudgetAlertThresholds, err = loadIntListVar(
+		BudgetAlertThresholdsKey,
+		defaultBudgetAlertThresholds,
+	)
 	if err != nil {
 		log.Fatal(err.Error())
 	}
@@ -104,6 +196,30 @@ func loadLambdaStoreVars() []string {
 	return nil
 }
 
+func loadStorageVars() []string {
+	StorageBackend = loadOptionalVar(StorageBackendKey, MongoBackend)
+
+	switch StorageBackend {
+	case MongoBackend:
+		return loadMongoDBVars()
+	case SQLiteBackend:
+		SQLitePath = loadOptionalVar(SQLitePathKey, defaultSQLitePath)
+	case MemoryBackend:
+	default:
+		return []string{
+			fmt.Sprintf(
+				"%q environment variable must be one of %q, %q or %q",
+				StorageBackendKey,
+				MongoBackend,
+				SQLiteBackend,
+				MemoryBackend,
+			),
+		}
+	}
+
+	return nil
+}
+
 func loadMongoDBVars() []string {
 	mongoVars := map[string]string{
 		MongoHostKey:     "",
@@ -132,6 +248,89 @@ func loadVar(key string) (string, error) {
 	return variable, nil
 }
 
+// loadTaskLists reads the lists from TASK_LISTS, a JSON array such as
+// [{"id": "<list id>", "account": "credit card"}]. When it is not set, the
+// single list in TASK_LIST_ID is used without an account.
+func loadTaskLists() ([]TaskList, error) {
+	variable := os.Getenv(TaskListsKey)
+	if variable == "" {
+		var err error
+
+		TaskListID, err = loadVar(TaskListIDKey)
+		if err != nil {
+			return nil, err
+		}
+
+		return []TaskList{{ID: TaskListID}}, nil
+	}
+
+	var lists []TaskList
+
+	err := json.Unmarshal([]byte(variable), &lists)
+	if err != nil {
+		return nil, fmt.Errorf("%q environment variable is invalid: %v", TaskListsKey, err)
+	}
+
+	if len(lists) == 0 {
+		return nil, fmt.Errorf("%q environment variable must have at least one list", TaskListsKey)
+	}
+
+	for _, list := range lists {
+		if list.ID == "" {
+			return nil, fmt.Errorf("%q environment variable has a list without id", TaskListsKey)
+		}
+	}
+
+	TaskListID = lists[0].ID
+	return lists, nil
+}
+
+func loadOptionalVar(key string, defaultValue string) string {
+	variable := os.Getenv(key)
+	if variable == "" {
+		return defaultValue
+	}
+
+	return variable
+}
+
+func loadIntVar(key string, defaultValue int) (int, error) {
+	variable := os.Getenv(key)
+	if variable == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.Atoi(variable)
+	if err != nil || value <= 0 {
+		return 0, errors.New(
+			fmt.Sprintf("%q environment variable must be a positive integer", key),
+		)
+	}
+
+	return value, nil
+}
+
+// loadIntListVar reads a comma separated list of positive integers, sorted
+// in ascending order.
+func loadIntListVar(key string, defaultValue string) ([]int, error) {
+	var values []int
+
+	for _, item := range strings.Split(loadOptionalVar(key, defaultValue), ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf(
+				"%q environment variable must be a comma separated list of positive integers",
+				key,
+			)
+		}
+
+		values = append(values, value)
+	}
+
+	sort.Ints(values)
+	return values, nil
+}
+
 func loadVarGroup(envKeys *map[string]string, groupName string) []string {
 	localSlice := make([]string, 0)
 
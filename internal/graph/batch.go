@@ -0,0 +1,170 @@
+// Package graph talks to the Microsoft Graph API.
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	BatchUrl     = "https://graph.microsoft.com/v1.0/$batch"
+	MaxBatchSize = 20
+)
+
+type BatchRequest struct {
+	ID      string            `json:"id"`
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    interface{}       `json:"body,omitempty"`
+}
+
+type BatchResponse struct {
+	ID      string            `json:"id"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+func (r *BatchResponse) Succeeded() bool {
+	return r.Status < 400
+}
+
+// Err describes a failed response, or is nil when it succeeded.
+func (r *BatchResponse) Err() error {
+	if r.Succeeded() {
+		return nil
+	}
+
+	return ParseError(r.Status, r.Body)
+}
+
+type batchPayload struct {
+	Requests []BatchRequest `json:"requests"`
+}
+
+type batchResult struct {
+	Responses []BatchResponse `json:"responses"`
+}
+
+// NewPatch builds a batch request that patches the resource at url, which
+// must be relative to the API version, such as "/me/todo/lists".
+func NewPatch(id string, url string, body interface{}) BatchRequest {
+	return BatchRequest{
+		ID:      id,
+		Method:  http.MethodPatch,
+		URL:     url,
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Body:    body,
+	}
+}
+
+// Batch sends the requests through the JSON batch endpoint in groups of
+// MaxBatchSize, returning the responses by request ID. Throttled requests
+// are sent again after the time asked by Graph, as long as the context
+// deadline allows it, so every request must be safe to send twice.
+func (c *Client) Batch(
+	ctx context.Context,
+	requests []BatchRequest,
+) (map[string]BatchResponse, error) {
+	responses := make(map[string]BatchResponse, len(requests))
+
+	for start := 0; start < len(requests); start += MaxBatchSize {
+		end := start + MaxBatchSize
+		if end > len(requests) {
+			end = len(requests)
+		}
+
+		err := c.sendBatch(ctx, requests[start:end], responses)
+		if err != nil {
+			return responses, err
+		}
+	}
+
+	return responses, nil
+}
+
+func (c *Client) sendBatch(
+	ctx context.Context,
+	requests []BatchRequest,
+	responses map[string]BatchResponse,
+) error {
+	pending := requests
+
+	for attempt := 1; len(pending) > 0; attempt++ {
+		var result batchResult
+
+		// the batched requests are patches, safe to send again
+		err := c.do(
+			ctx,
+			http.MethodPost,
+			BatchUrl,
+			batchPayload{Requests: pending},
+			&result,
+			retryAll,
+		)
+		if err != nil {
+			return err
+		}
+
+		var throttled []BatchRequest
+		var wait time.Duration
+
+		for _, response := range result.Responses {
+			responses[response.ID] = response
+
+			if response.Status == http.StatusTooManyRequests {
+				throttled = append(throttled, findRequest(pending, response.ID))
+
+				if w := RetryAfter(response.Headers["Retry-After"]); w > wait {
+					wait = w
+				}
+			}
+		}
+
+		if len(throttled) == 0 || attempt >= c.MaxAttempts {
+			return nil
+		}
+
+		if wait == 0 {
+			wait = c.backoff(attempt)
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			log.Printf(
+				"not retrying %v throttled batch requests, it would exceed the time budget\n",
+				len(throttled),
+			)
+			return nil
+		}
+
+		log.Printf(
+			"%v batch requests were throttled, retrying in %v...\n",
+			len(throttled),
+			wait,
+		)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+
+		pending = throttled
+	}
+
+	return nil
+}
+
+func findRequest(requests []BatchRequest, id string) BatchRequest {
+	for _, request := range requests {
+		if request.ID == id {
+			return request
+		}
+	}
+
+	return BatchRequest{}
+}
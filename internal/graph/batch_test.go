@@ -0,0 +1,155 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// redirect sends every request to the server, whatever its URL, so the
+// batch endpoint can be served by a test server.
+type redirect struct {
+	server *httptest.Server
+}
+
+func (r redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	target, err := url.Parse(r.server.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.URL.Scheme = target.Scheme
+	req.URL.Host = target.Host
+
+	return r.server.Client().Transport.RoundTrip(req)
+}
+
+// newBatchServer serves batches with respond, recording the IDs of the
+// requests of every batch it receives.
+func newBatchServer(
+	t *testing.T,
+	batches *[][]string,
+	respond func(request BatchRequest) BatchResponse,
+) *http.Client {
+	t.Helper()
+
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var payload batchPayload
+
+			err := json.NewDecoder(r.Body).Decode(&payload)
+			if err != nil {
+				t.Errorf("could not decode the batch: %v", err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			var ids []string
+			var result batchResult
+
+			// responses come in any order, so they are sent reversed
+			for i := len(payload.Requests) - 1; i >= 0; i-- {
+				ids = append([]string{payload.Requests[i].ID}, ids...)
+				result.Responses = append(result.Responses, respond(payload.Requests[i]))
+			}
+
+			*batches = append(*batches, ids)
+
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(result)
+		}),
+	)
+	t.Cleanup(server.Close)
+
+	return &http.Client{Transport: redirect{server: server}}
+}
+
+func newBatchRequests(count int) []BatchRequest {
+	requests := make([]BatchRequest, count)
+	for i := range requests {
+		id := strconv.Itoa(i)
+		requests[i] = NewPatch(id, "/me/todo/lists/list/tasks/"+id, nil)
+	}
+
+	return requests
+}
+
+func TestBatchSplitsInGroups(t *testing.T) {
+	var batches [][]string
+	client := newBatchServer(t, &batches, func(request BatchRequest) BatchResponse {
+		body, _ := json.Marshal(request.URL)
+		return BatchResponse{ID: request.ID, Status: http.StatusOK, Body: body}
+	})
+
+	requests := newBatchRequests(2*MaxBatchSize + 5)
+
+	responses, err := NewClient(client).Batch(context.Background(), requests)
+	if err != nil {
+		t.Fatalf("Batch returned an error: %v", err)
+	}
+
+	var sizes []int
+	for _, batch := range batches {
+		sizes = append(sizes, len(batch))
+	}
+
+	if want := []int{MaxBatchSize, MaxBatchSize, 5}; !reflect.DeepEqual(sizes, want) {
+		t.Errorf("sent batches of %v requests, want %v", sizes, want)
+	}
+
+	if len(responses) != len(requests) {
+		t.Fatalf("got %v responses, want %v", len(responses), len(requests))
+	}
+
+	for _, request := range requests {
+		var got string
+
+		response := responses[request.ID]
+		if err := json.Unmarshal(response.Body, &got); err != nil || got != request.URL {
+			t.Errorf("request %v got the response of %q", request.ID, got)
+		}
+	}
+}
+
+func TestBatchRetriesThrottled(t *testing.T) {
+	var batches [][]string
+	client := newBatchServer(t, &batches, func(request BatchRequest) BatchResponse {
+		if request.ID == "1" && len(batches) == 0 {
+			return BatchResponse{
+				ID:      request.ID,
+				Status:  http.StatusTooManyRequests,
+				Headers: map[string]string{"Retry-After": "1"},
+			}
+		}
+
+		return BatchResponse{ID: request.ID, Status: http.StatusNoContent}
+	})
+
+	start := time.Now()
+
+	responses, err := NewClient(client).Batch(context.Background(), newBatchRequests(3))
+	if err != nil {
+		t.Fatalf("Batch returned an error: %v", err)
+	}
+
+	if want := [][]string{{"0", "1", "2"}, {"1"}}; !reflect.DeepEqual(batches, want) {
+		t.Errorf("sent the batches %v, want %v", batches, want)
+	}
+
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want the second asked by Retry-After", elapsed)
+	}
+
+	for id, response := range responses {
+		if !response.Succeeded() {
+			t.Errorf("request %v failed: %v", id, response.Err())
+		}
+	}
+}
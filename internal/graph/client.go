@@ -0,0 +1,310 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retries tells which failed attempts of a request are sent again.
+type retries int
+
+const (
+	retryNone retries = iota
+	retryUnprocessed
+	retryAll
+)
+
+const (
+	defaultMaxAttempts = 5
+	defaultBaseDelay   = 250 * time.Millisecond
+	defaultMaxDelay    = 4 * time.Second
+)
+
+// Client sends requests to Graph, retrying throttled requests, unavailable
+// services and network errors with exponential backoff and jitter, or after
+// the time asked by Graph in the Retry-After header. Retries stop when the
+// context of the request is done, so its deadline is the time budget of
+// every call.
+//
+// POST requests are not idempotent, so they are only retried when Graph
+// surely did not process them: when throttled or when the connection could
+// not be made.
+type Client struct {
+	HTTPClient  *http.Client
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+func NewClient(httpClient *http.Client) *Client {
+	return &Client{
+		HTTPClient:  httpClient,
+		MaxAttempts: defaultMaxAttempts,
+		BaseDelay:   defaultBaseDelay,
+		MaxDelay:    defaultMaxDelay,
+	}
+}
+
+// Error is an error response from Graph, read from its JSON error envelope.
+type Error struct {
+	StatusCode int
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	InnerError struct {
+		RequestID string `json:"request-id"`
+		Date      string `json:"date"`
+	} `json:"innerError"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("graph error (status %v): %v", e.StatusCode, e.Message)
+	}
+
+	return fmt.Sprintf(
+		"graph error %v (status %v): %v",
+		e.Code,
+		e.StatusCode,
+		e.Message,
+	)
+}
+
+// Temporary tells whether the request may succeed if sent again.
+func (e *Error) Temporary() bool {
+	return isRetryableStatus(e.StatusCode)
+}
+
+type errorEnvelope struct {
+	Error *Error `json:"error"`
+}
+
+// ParseError reads the error envelope of a failed response body. Bodies
+// that are not an envelope are kept as the message.
+func ParseError(statusCode int, body []byte) *Error {
+	var envelope errorEnvelope
+
+	if json.Unmarshal(body, &envelope) != nil || envelope.Error == nil {
+		return &Error{StatusCode: statusCode, Message: string(body)}
+	}
+
+	envelope.Error.StatusCode = statusCode
+	return envelope.Error
+}
+
+func (c *Client) Get(ctx context.Context, url string, out interface{}) error {
+	return c.Do(ctx, http.MethodGet, url, nil, out)
+}
+
+func (c *Client) Post(
+	ctx context.Context,
+	url string,
+	body interface{},
+	out interface{},
+) error {
+	return c.Do(ctx, http.MethodPost, url, body, out)
+}
+
+// PostOnce is Post without any retry, for requests that must never be sent
+// twice.
+func (c *Client) PostOnce(
+	ctx context.Context,
+	url string,
+	body interface{},
+	out interface{},
+) error {
+	return c.do(ctx, http.MethodPost, url, body, out, retryNone)
+}
+
+func (c *Client) Patch(
+	ctx context.Context,
+	url string,
+	body interface{},
+	out interface{},
+) error {
+	return c.Do(ctx, http.MethodPatch, url, body, out)
+}
+
+// Do sends the body as JSON and decodes the JSON response into out, when
+// out is not nil. Failed responses are returned as *Error.
+func (c *Client) Do(
+	ctx context.Context,
+	method string,
+	url string,
+	body interface{},
+	out interface{},
+) error {
+	policy := retryAll
+	if method == http.MethodPost {
+		policy = retryUnprocessed
+	}
+
+	return c.do(ctx, method, url, body, out, policy)
+}
+
+func (c *Client) do(
+	ctx context.Context,
+	method string,
+	url string,
+	body interface{},
+	out interface{},
+	policy retries,
+) error {
+	var payload []byte
+
+	if body != nil {
+		var err error
+
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		wait, err := c.send(ctx, method, url, payload, out, policy)
+		if err == nil {
+			return nil
+		}
+
+		if wait < 0 || attempt >= c.MaxAttempts {
+			return err
+		}
+
+		if wait == 0 {
+			wait = c.backoff(attempt)
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			log.Printf("not retrying %v %q, it would exceed the time budget\n", method, url)
+			return err
+		}
+
+		log.Printf(
+			"%v %q failed (%v), retrying in %v...\n",
+			method,
+			url,
+			err,
+			wait,
+		)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// send makes a single attempt. It returns how long to wait before trying
+// again: zero to back off, or a negative duration when it must not be
+// retried under the policy.
+func (c *Client) send(
+	ctx context.Context,
+	method string,
+	url string,
+	payload []byte,
+	out interface{},
+	policy retries,
+) (time.Duration, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return -1, err
+	}
+
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil || policy == retryNone ||
+			(policy == retryUnprocessed && !isDialError(err)) {
+			return -1, err
+		}
+
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			if policy != retryAll {
+				return -1, err
+			}
+
+			return 0, err
+		}
+
+		graphErr := ParseError(resp.StatusCode, bodyBytes)
+		if !graphErr.Temporary() || policy == retryNone ||
+			(policy == retryUnprocessed && resp.StatusCode != http.StatusTooManyRequests) {
+			return -1, graphErr
+		}
+
+		return RetryAfter(resp.Header.Get("Retry-After")), graphErr
+	}
+
+	if out == nil {
+		return 0, nil
+	}
+
+	return -1, json.NewDecoder(resp.Body).Decode(out)
+}
+
+// backoff is the exponential delay of an attempt with full jitter.
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.BaseDelay << uint(attempt-1)
+	if delay <= 0 || delay > c.MaxDelay {
+		delay = c.MaxDelay
+	}
+
+	return time.Duration(rand.Int63n(int64(delay)) + 1)
+}
+
+// RetryAfter reads the seconds of a Retry-After header, or zero when there
+// is none.
+func RetryAfter(header string) time.Duration {
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// isDialError tells whether the connection could not be made, so the
+// request never reached Graph.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,120 @@
+package graph
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		once     bool
+		status   int
+		requests int
+	}{
+		{name: "get unavailable", method: http.MethodGet, status: http.StatusServiceUnavailable, requests: 3},
+		{name: "get throttled", method: http.MethodGet, status: http.StatusTooManyRequests, requests: 3},
+		{name: "get not found", method: http.MethodGet, status: http.StatusNotFound, requests: 1},
+		{name: "patch bad gateway", method: http.MethodPatch, status: http.StatusBadGateway, requests: 3},
+		{name: "post throttled", method: http.MethodPost, status: http.StatusTooManyRequests, requests: 3},
+		{name: "post unavailable", method: http.MethodPost, status: http.StatusServiceUnavailable, requests: 1},
+		{name: "post gateway timeout", method: http.MethodPost, status: http.StatusGatewayTimeout, requests: 1},
+		{name: "post once throttled", method: http.MethodPost, once: true, status: http.StatusTooManyRequests, requests: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					requests++
+					w.WriteHeader(test.status)
+				}),
+			)
+			defer server.Close()
+
+			client := NewClient(server.Client())
+			client.MaxAttempts = 3
+			client.BaseDelay = time.Millisecond
+			client.MaxDelay = time.Millisecond
+
+			var err error
+			if test.once {
+				err = client.PostOnce(context.Background(), server.URL, struct{}{}, nil)
+			} else {
+				err = client.Do(context.Background(), test.method, server.URL, struct{}{}, nil)
+			}
+
+			graphErr, ok := err.(*Error)
+			if !ok || graphErr.StatusCode != test.status {
+				t.Fatalf("got error %v, want status %v", err, test.status)
+			}
+
+			if requests != test.requests {
+				t.Errorf("sent %v requests, want %v", requests, test.requests)
+			}
+		})
+	}
+}
+
+func TestRetriesConnectionRefused(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	client := NewClient(http.DefaultClient)
+	client.BaseDelay = time.Millisecond
+	client.MaxDelay = time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := client.Post(ctx, url, struct{}{}, nil); !isDialError(err) {
+		t.Errorf("got error %v, want a dial error", err)
+	}
+}
+
+func TestRetriesUnreadableError(t *testing.T) {
+	tests := []struct {
+		method   string
+		requests int
+	}{
+		{method: http.MethodGet, requests: 3},
+		{method: http.MethodPost, requests: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					requests++
+
+					// the body is cut short, so it cannot be read
+					w.Header().Set("Content-Length", "100")
+					w.WriteHeader(http.StatusServiceUnavailable)
+					_, _ = w.Write([]byte("unavailable"))
+				}),
+			)
+			defer server.Close()
+
+			client := NewClient(server.Client())
+			client.MaxAttempts = 3
+			client.BaseDelay = time.Millisecond
+			client.MaxDelay = time.Millisecond
+
+			err := client.Do(context.Background(), test.method, server.URL, struct{}{}, nil)
+			if err == nil {
+				t.Fatal("got no error")
+			}
+
+			if requests != test.requests {
+				t.Errorf("sent %v requests, want %v", requests, test.requests)
+			}
+		})
+	}
+}
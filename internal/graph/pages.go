@@ -0,0 +1,50 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+)
+
+// page is a page of a collection, its value left to the caller to decode.
+type page struct {
+	Value    json.RawMessage `json:"value"`
+	NextLink string          `json:"@odata.nextLink"`
+}
+
+// GetPages reads a collection that Graph splits in pages, following the
+// @odata.nextLink of each page and handing its value to add. It stops after
+// maxPages, returning how many pages were read and whether any was left.
+func (c *Client) GetPages(
+	ctx context.Context,
+	url string,
+	maxPages int,
+	add func(value json.RawMessage) error,
+) (int, bool, error) {
+	pages := 0
+
+	for url != "" {
+		if pages >= maxPages {
+			return pages, true, nil
+		}
+
+		var p page
+
+		log.Printf("listing %q...\n", url)
+		err := c.Get(ctx, url, &p)
+		if err != nil {
+			return pages, false, err
+		}
+
+		pages++
+
+		err = add(p.Value)
+		if err != nil {
+			return pages, false, err
+		}
+
+		url = p.NextLink
+	}
+
+	return pages, false, nil
+}
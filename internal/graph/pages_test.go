@@ -0,0 +1,154 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/jbonadiman/finances-api/internal/models"
+)
+
+// taskPages are served in order, each page linking to the next one.
+var taskPages = [][]string{
+	{"1", "2"},
+	{"3"},
+	{"4", "5"},
+}
+
+func newTaskServer(t *testing.T, requests *int) *httptest.Server {
+	t.Helper()
+
+	var server *httptest.Server
+	server = httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*requests++
+
+			index, err := strconv.Atoi(r.URL.Query().Get("page"))
+			if err != nil || index >= len(taskPages) {
+				http.NotFound(w, r)
+				return
+			}
+
+			value := make([]models.Task, len(taskPages[index]))
+			for i, id := range taskPages[index] {
+				value[i] = models.Task{Id: id, Title: "10;task " + id}
+			}
+
+			body := map[string]interface{}{"value": value}
+			if index+1 < len(taskPages) {
+				body["@odata.nextLink"] = fmt.Sprintf("%v/tasks?page=%v", server.URL, index+1)
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(body)
+		}),
+	)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func getTasks(
+	client *Client,
+	url string,
+	maxPages int,
+) ([]string, int, bool, error) {
+	var ids []string
+
+	pages, more, err := client.GetPages(
+		context.Background(),
+		url,
+		maxPages,
+		func(value json.RawMessage) error {
+			var page []models.Task
+
+			err := json.Unmarshal(value, &page)
+			for _, task := range page {
+				ids = append(ids, task.Id)
+			}
+
+			return err
+		},
+	)
+
+	return ids, pages, more, err
+}
+
+func TestGetPages(t *testing.T) {
+	tests := []struct {
+		maxPages int
+		ids      []string
+		pages    int
+		more     bool
+	}{
+		{maxPages: 10, ids: []string{"1", "2", "3", "4", "5"}, pages: 3},
+		{maxPages: 3, ids: []string{"1", "2", "3", "4", "5"}, pages: 3},
+		{maxPages: 2, ids: []string{"1", "2", "3"}, pages: 2, more: true},
+		{maxPages: 1, ids: []string{"1", "2"}, pages: 1, more: true},
+		{maxPages: 0, pages: 0, more: true},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("max %v pages", test.maxPages), func(t *testing.T) {
+			requests := 0
+			server := newTaskServer(t, &requests)
+
+			ids, pages, more, err := getTasks(
+				NewClient(server.Client()),
+				server.URL+"/tasks?page=0",
+				test.maxPages,
+			)
+			if err != nil {
+				t.Fatalf("GetPages returned an error: %v", err)
+			}
+
+			if !reflect.DeepEqual(ids, test.ids) {
+				t.Errorf("got tasks %v, want %v", ids, test.ids)
+			}
+
+			if pages != test.pages || requests != test.pages {
+				t.Errorf(
+					"read %v pages in %v requests, want %v",
+					pages,
+					requests,
+					test.pages,
+				)
+			}
+
+			if more != test.more {
+				t.Errorf("got more pages left %v, want %v", more, test.more)
+			}
+		})
+	}
+}
+
+func TestGetPagesFailedPage(t *testing.T) {
+	requests := 0
+	server := newTaskServer(t, &requests)
+
+	ids, pages, _, err := getTasks(
+		NewClient(server.Client()),
+		server.URL+"/tasks?page=1",
+		10,
+	)
+
+	if !reflect.DeepEqual(ids, []string{"3", "4", "5"}) || pages != 2 || err != nil {
+		t.Fatalf("got tasks %v in %v pages (%v), want the last two pages", ids, pages, err)
+	}
+
+	_, pages, _, err = getTasks(
+		NewClient(server.Client()),
+		server.URL+"/tasks?page=3",
+		10,
+	)
+
+	graphErr, ok := err.(*Error)
+	if !ok || graphErr.StatusCode != http.StatusNotFound || pages != 0 {
+		t.Fatalf("got %v pages and error %v, want a not found error", pages, err)
+	}
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+// Intervals of a balance history
+const (
+	IntervalDay   = "day"
+	IntervalMonth = "month"
+)
+
+// IntervalLayouts are the time layouts of the periods of each interval
+var IntervalLayouts = map[string]string{
+	IntervalDay:   "2006-01-02",
+	IntervalMonth: MonthLayout,
+}
+
+// Movement is how much the balance of an account changed in a period.
+type Movement struct {
+	AccountID primitive.ObjectID `json:"accountId" bson:"accountId"`
+	Period    string             `json:"period" bson:"period"`
+	Change    float64            `json:"change" bson:"change"`
+}
+
+type BalancePoint struct {
+	Period  string  `json:"period"`
+	Change  float64 `json:"change"`
+	Balance float64 `json:"balance"`
+}
+
+type AccountBalance struct {
+	Account entities.Account `json:"account"`
+	Balance float64          `json:"balance"`
+	History []BalancePoint   `json:"history"`
+}
+
+// Movements groups the given transactions by account and period the same
+// way the database aggregation does, for storages that cannot aggregate by
+// themselves. Transactions without an account are left out.
+func Movements(transactions []entities.Transaction, interval string) []Movement {
+	layout := IntervalLayouts[interval]
+
+	type key struct {
+		account primitive.ObjectID
+		period  string
+	}
+
+	changes := make(map[key]float64)
+	for _, t := range transactions {
+		if t.AccountID.IsZero() {
+			continue
+		}
+
+		k := key{t.AccountID, t.Date.UTC().Format(layout)}
+		changes[k] += t.BalanceChange()
+	}
+
+	movements := make([]Movement, 0, len(changes))
+	for k, change := range changes {
+		movements = append(movements, Movement{
+			AccountID: k.account,
+			Period:    k.period,
+			Change:    change,
+		})
+	}
+
+	return movements
+}
+
+// RunningBalance accumulates the movements of an account over its opening
+// balance, in chronological order.
+func RunningBalance(account entities.Account, movements []Movement) AccountBalance {
+	var own []Movement
+	for _, m := range movements {
+		if m.AccountID == account.ID {
+			own = append(own, m)
+		}
+	}
+
+	sort.Slice(own, func(i, j int) bool {
+		return own[i].Period < own[j].Period
+	})
+
+	balance := AccountBalance{
+		Account: account,
+		Balance: account.OpeningBalance,
+		History: make([]BalancePoint, 0, len(own)),
+	}
+
+	for _, m := range own {
+		balance.Balance = roundCents(balance.Balance + m.Change)
+		balance.History = append(balance.History, BalancePoint{
+			Period:  m.Period,
+			Change:  roundCents(m.Change),
+			Balance: balance.Balance,
+		})
+	}
+
+	return balance
+}
@@ -0,0 +1,186 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+// BudgetStatus compares the spending of a month against its budget. Limit
+// is the budget limit plus what was rolled over from the previous month.
+type BudgetStatus struct {
+	Budget      entities.Budget `json:"budget"`
+	RolledOver  float64         `json:"rolledOver"`
+	Limit       float64         `json:"limit"`
+	Spent       float64         `json:"spent"`
+	Remaining   float64         `json:"remaining"`
+	PercentUsed *float64        `json:"percentUsed"`
+	Projected   float64         `json:"projected"`
+	Overspent   bool            `json:"overspent"`
+}
+
+// Spending is how much was spent on each subcategory and category.
+type Spending struct {
+	bySubcategory map[string]float64
+	byCategory    map[string]float64
+}
+
+func SpendingOf(summary *Summary) Spending {
+	spending := Spending{
+		bySubcategory: make(map[string]float64),
+		byCategory:    make(map[string]float64),
+	}
+
+	for _, group := range summary.BySubcategory {
+		spending.bySubcategory[group.Key] = group.Total
+	}
+
+	for _, group := range summary.ByCategory {
+		spending.byCategory[group.Key] = group.Total
+	}
+
+	return spending
+}
+
+// Of returns how much was spent on the subcategory or category the budget
+// limits.
+func (s Spending) Of(budget *entities.Budget) float64 {
+	if budget.Scope == entities.BudgetCategory {
+		return s.byCategory[budget.Name]
+	}
+
+	return s.bySubcategory[budget.Name]
+}
+
+// NewBudgetStatus compares the spending against the budget, rolling over
+// what was left of the previous budget when there is one. Only the previous
+// budget's own limit rolls over, not what it had rolled over itself, so
+// unspent money carries over for one month at most. Spending is projected
+// to the end of the month at the pace of the days elapsed by now.
+func NewBudgetStatus(
+	budget *entities.Budget,
+	spent float64,
+	previous *entities.Budget,
+	previousSpent float64,
+	now time.Time,
+) BudgetStatus {
+	status := BudgetStatus{
+		Budget: *budget,
+		Limit:  budget.Limit,
+		Spent:  roundCents(spent),
+	}
+
+	if budget.Rollover && previous != nil && previous.Limit > previousSpent {
+		status.RolledOver = roundCents(previous.Limit - previousSpent)
+		status.Limit = roundCents(status.Limit + status.RolledOver)
+	}
+
+	status.Remaining = roundCents(status.Limit - status.Spent)
+	status.Overspent = status.Spent > status.Limit
+
+	if status.Limit > 0 {
+		percent := roundCents(status.Spent / status.Limit * 100)
+		status.PercentUsed = &percent
+	}
+
+	status.Projected = roundCents(projectSpending(budget.Month, status.Spent, now))
+
+	return status
+}
+
+// projectSpending extrapolates the spending of a month from the days
+// elapsed by now. Past months are not projected, and months yet to come
+// have nothing to project.
+func projectSpending(month string, spent float64, now time.Time) float64 {
+	start, err := time.Parse(MonthLayout, month)
+	if err != nil {
+		return spent
+	}
+
+	end := start.AddDate(0, 1, 0)
+	now = now.UTC()
+
+	if !now.Before(end) || now.Before(start) {
+		return spent
+	}
+
+	days := end.AddDate(0, 0, -1).Day()
+	elapsed := now.Day()
+
+	return spent / float64(elapsed) * float64(days)
+}
+
+// PreviousMonth returns the month before the given one, in MonthLayout.
+func PreviousMonth(month string) (string, error) {
+	start, err := time.Parse(MonthLayout, month)
+	if err != nil {
+		return "", err
+	}
+
+	return start.AddDate(0, -1, 0).Format(MonthLayout), nil
+}
+
+// RenameBudgets moves the budgets of any of the old subcategories to the
+// new one. When a month ends up with more than one budget for the new
+// subcategory, they are merged into one adding up their limits. As budgets
+// are stored by name, the budgets in removed must be deleted before the
+// renamed ones are stored.
+func RenameBudgets(
+	budgets []entities.Budget,
+	oldNames []string,
+	newName string,
+) (renamed []entities.Budget, removed []primitive.ObjectID) {
+	var months []string
+	byMonth := make(map[string][]entities.Budget)
+
+	for _, budget := range budgets {
+		if budget.Scope != entities.BudgetSubcategory ||
+			(budget.Name != newName && !contains(oldNames, budget.Name)) {
+			continue
+		}
+
+		if _, ok := byMonth[budget.Month]; !ok {
+			months = append(months, budget.Month)
+		}
+
+		byMonth[budget.Month] = append(byMonth[budget.Month], budget)
+	}
+
+	for _, month := range months {
+		group := byMonth[month]
+
+		kept := 0
+		for i, budget := range group {
+			if budget.Name == newName {
+				kept = i
+				break
+			}
+		}
+
+		if len(group) == 1 && group[kept].Name == newName {
+			continue
+		}
+
+		budget := group[kept]
+		if budget.Name != newName {
+			removed = append(removed, budget.ID)
+			budget.Name = newName
+		}
+
+		for i, other := range group {
+			if i == kept {
+				continue
+			}
+
+			budget.Limit = roundCents(budget.Limit + other.Limit)
+			budget.Rollover = budget.Rollover || other.Rollover
+			removed = append(removed, other.ID)
+		}
+
+		renamed = append(renamed, budget)
+	}
+
+	return renamed, removed
+}
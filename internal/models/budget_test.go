@@ -0,0 +1,253 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+func percent(value float64) *float64 {
+	return &value
+}
+
+func TestNewBudgetStatus(t *testing.T) {
+	// past the end of February, so nothing is projected
+	now := time.Date(2021, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		budget        entities.Budget
+		spent         float64
+		previous      *entities.Budget
+		previousSpent float64
+		want          BudgetStatus
+	}{
+		{
+			name:   "without rollover",
+			budget: entities.Budget{Month: "2021-02", Limit: 500},
+			spent:  200,
+			previous: &entities.Budget{
+				Month: "2021-01",
+				Limit: 500,
+			},
+			previousSpent: 100,
+			want: BudgetStatus{
+				Limit:       500,
+				Spent:       200,
+				Remaining:   300,
+				PercentUsed: percent(40),
+				Projected:   200,
+			},
+		},
+		{
+			name:          "rollover",
+			budget:        entities.Budget{Month: "2021-02", Limit: 500, Rollover: true},
+			spent:         350,
+			previous:      &entities.Budget{Month: "2021-01", Limit: 300},
+			previousSpent: 100,
+			want: BudgetStatus{
+				RolledOver:  200,
+				Limit:       700,
+				Spent:       350,
+				Remaining:   350,
+				PercentUsed: percent(50),
+				Projected:   350,
+			},
+		},
+		{
+			name:   "rollover of a single month",
+			budget: entities.Budget{Month: "2021-02", Limit: 500, Rollover: true},
+			spent:  100,
+			previous: &entities.Budget{
+				Month:    "2021-01",
+				Limit:    300,
+				Rollover: true,
+			},
+			previousSpent: 400,
+			want: BudgetStatus{
+				Limit:       500,
+				Spent:       100,
+				Remaining:   400,
+				PercentUsed: percent(20),
+				Projected:   100,
+			},
+		},
+		{
+			name:   "rollover without a previous budget",
+			budget: entities.Budget{Month: "2021-02", Limit: 500, Rollover: true},
+			spent:  100,
+			want: BudgetStatus{
+				Limit:       500,
+				Spent:       100,
+				Remaining:   400,
+				PercentUsed: percent(20),
+				Projected:   100,
+			},
+		},
+		{
+			name:   "overspent",
+			budget: entities.Budget{Month: "2021-02", Limit: 500},
+			spent:  600,
+			want: BudgetStatus{
+				Limit:       500,
+				Spent:       600,
+				Remaining:   -100,
+				PercentUsed: percent(120),
+				Projected:   600,
+				Overspent:   true,
+			},
+		},
+		{
+			name:   "zero limit",
+			budget: entities.Budget{Month: "2021-02"},
+			spent:  10,
+			want: BudgetStatus{
+				Spent:     10,
+				Remaining: -10,
+				Projected: 10,
+				Overspent: true,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.want.Budget = test.budget
+
+			got := NewBudgetStatus(&test.budget, test.spent, test.previous, test.previousSpent, now)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("NewBudgetStatus() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewBudgetStatusProjects(t *testing.T) {
+	budget := entities.Budget{Month: "2021-02", Limit: 500}
+	now := time.Date(2021, 2, 14, 12, 0, 0, 0, time.UTC)
+
+	got := NewBudgetStatus(&budget, 100, nil, 0, now)
+	if got.Projected != 200 {
+		t.Errorf("projected %v, want 200", got.Projected)
+	}
+}
+
+func TestProjectSpending(t *testing.T) {
+	tests := []struct {
+		name  string
+		month string
+		now   time.Time
+		want  float64
+	}{
+		{
+			name:  "past month",
+			month: "2021-02",
+			now:   time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC),
+			want:  100,
+		},
+		{
+			name:  "month yet to come",
+			month: "2021-02",
+			now:   time.Date(2021, 1, 31, 23, 0, 0, 0, time.UTC),
+			want:  100,
+		},
+		{
+			name:  "first day",
+			month: "2021-02",
+			now:   time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC),
+			want:  2800,
+		},
+		{
+			name:  "middle of the month",
+			month: "2021-02",
+			now:   time.Date(2021, 2, 14, 12, 0, 0, 0, time.UTC),
+			want:  200,
+		},
+		{
+			name:  "last day",
+			month: "2021-04",
+			now:   time.Date(2021, 4, 30, 23, 0, 0, 0, time.UTC),
+			want:  100,
+		},
+		{
+			name:  "other time zone",
+			month: "2021-02",
+			now:   time.Date(2021, 2, 28, 22, 0, 0, 0, time.FixedZone("BRT", -3*60*60)),
+			want:  100,
+		},
+		{
+			name:  "invalid month",
+			month: "2021-13",
+			now:   time.Date(2021, 2, 14, 0, 0, 0, 0, time.UTC),
+			want:  100,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := projectSpending(test.month, 100, test.now)
+			if got != test.want {
+				t.Errorf("projectSpending(%q, 100, %v) = %v, want %v", test.month, test.now, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRenameBudgets(t *testing.T) {
+	ids := make([]primitive.ObjectID, 6)
+	for i := range ids {
+		ids[i] = primitive.NewObjectID()
+	}
+
+	budgets := []entities.Budget{
+		{ID: ids[0], Scope: entities.BudgetSubcategory, Name: "streaming", Month: "2021-01", Limit: 50},
+		{ID: ids[1], Scope: entities.BudgetSubcategory, Name: "streaming", Month: "2021-02", Limit: 50},
+		{ID: ids[2], Scope: entities.BudgetSubcategory, Name: "assinaturas", Month: "2021-02", Limit: 30.1, Rollover: true},
+		{ID: ids[3], Scope: entities.BudgetSubcategory, Name: "musica", Month: "2021-02", Limit: 20.2},
+		{ID: ids[4], Scope: entities.BudgetCategory, Name: "streaming", Month: "2021-02", Limit: 100},
+		{ID: ids[5], Scope: entities.BudgetSubcategory, Name: "assinaturas", Month: "2021-03", Limit: 80},
+	}
+
+	renamed, removed := RenameBudgets(budgets, []string{"streaming", "musica"}, "assinaturas")
+
+	wantRenamed := []entities.Budget{
+		{ID: ids[0], Scope: entities.BudgetSubcategory, Name: "assinaturas", Month: "2021-01", Limit: 50},
+		{ID: ids[2], Scope: entities.BudgetSubcategory, Name: "assinaturas", Month: "2021-02", Limit: 100.3, Rollover: true},
+	}
+	if !reflect.DeepEqual(renamed, wantRenamed) {
+		t.Errorf("renamed %+v, want %+v", renamed, wantRenamed)
+	}
+
+	wantRemoved := []primitive.ObjectID{ids[0], ids[1], ids[3]}
+	if !reflect.DeepEqual(removed, wantRemoved) {
+		t.Errorf("removed %v, want %v", removed, wantRemoved)
+	}
+
+	if budgets[0].Name != "streaming" {
+		t.Errorf("the given budgets were changed: %+v", budgets)
+	}
+}
+
+func TestBudgetSurvivesRename(t *testing.T) {
+	budgets := []entities.Budget{
+		{Scope: entities.BudgetSubcategory, Name: "streaming", Month: "2021-02", Limit: 50},
+	}
+
+	renamed, _ := RenameBudgets(budgets, []string{"streaming"}, "assinaturas")
+	if len(renamed) != 1 {
+		t.Fatalf("renamed %v budgets, want 1", len(renamed))
+	}
+
+	// the transactions are renamed along with the budget
+	spending := SpendingOf(
+		&Summary{BySubcategory: []SummaryGroup{{Key: "assinaturas", Total: 45}}},
+	)
+
+	if got := spending.Of(&renamed[0]); got != 45 {
+		t.Errorf("the renamed budget counts %v as spent, want 45", got)
+	}
+}
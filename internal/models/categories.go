@@ -0,0 +1,28 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+// CategoriesBySubcategory maps the name of each subcategory to the name of
+// its category, leaving out subcategories without one.
+func CategoriesBySubcategory(
+	subcategories []entities.Subcategory,
+	categories []entities.Category,
+) map[string]string {
+	names := make(map[primitive.ObjectID]string)
+	for _, c := range categories {
+		names[c.ID] = c.Name
+	}
+
+	categoryOf := make(map[string]string)
+	for _, s := range subcategories {
+		if name, found := names[s.CategoryID]; found {
+			categoryOf[s.Name] = name
+		}
+	}
+
+	return categoryOf
+}
@@ -0,0 +1,9 @@
+package models
+
+import "errors"
+
+// Errors shared by the storage backends
+var (
+	ErrNotFound = errors.New("document not found")
+	ErrModified = errors.New("the document was modified since it was read")
+)
@@ -0,0 +1,60 @@
+package models
+
+import "github.com/jbonadiman/finances-api/internal/entities"
+
+// Outcomes of a task in an ingestion
+const (
+	TaskValid            = "valid"
+	TaskStored           = "stored"
+	TaskInvalid          = "invalid"
+	TaskSkipped          = "skipped"
+	TaskFailedToComplete = "failedToComplete"
+	TaskFailedToStore    = "failedToStore"
+)
+
+type TaskOutcome struct {
+	TaskID  string `json:"taskId"`
+	Title   string `json:"title"`
+	Outcome string `json:"outcome"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+type IngestionResult struct {
+	DryRun bool            `json:"dryRun,omitempty"`
+	Stored StoreResult     `json:"stored"`
+	Lists  []ListIngestion `json:"lists"`
+}
+
+// ListIngestion is the result of ingesting a single To Do list.
+type ListIngestion struct {
+	ListID       string                 `json:"listId"`
+	Account      string                 `json:"account,omitempty"`
+	Pages        int                    `json:"pages"`
+	Error        string                 `json:"error,omitempty"`
+	Stored       StoreResult            `json:"stored"`
+	Tasks        []TaskOutcome          `json:"tasks"`
+	Transactions []entities.Transaction `json:"transactions,omitempty"`
+}
+
+func (r *IngestionResult) Count(outcome string) int {
+	count := 0
+	for _, l := range r.Lists {
+		for _, t := range l.Tasks {
+			if t.Outcome == outcome {
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+func (r *IngestionResult) Failed() bool {
+	for _, l := range r.Lists {
+		if l.Error == "" {
+			return false
+		}
+	}
+
+	return len(r.Lists) > 0
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+// InstallmentSuffix keys each installment after the first on the task ID
+// followed by the installment number, since they all come from one task.
+const InstallmentSuffix = ":%v"
+
+// InstallmentPlan is every installment of a purchase along with what is
+// left to pay.
+type InstallmentPlan struct {
+	GroupID        primitive.ObjectID     `json:"groupId"`
+	Description    string                 `json:"description"`
+	Total          float64                `json:"total"`
+	Installments   []entities.Transaction `json:"installments"`
+	Remaining      int                    `json:"remaining"`
+	RemainingValue float64                `json:"remainingValue"`
+}
+
+// SplitInstallments splits the transaction into one transaction per month,
+// starting at its date and linked by a group ID. The cents that cannot be
+// split evenly are added to the first installment, so the installments
+// always add up to the original cost.
+func SplitInstallments(t entities.Transaction, count int) []entities.Transaction {
+	if count <= 1 {
+		return []entities.Transaction{t}
+	}
+
+	cents := int64(math.Round(t.Cost * 100))
+	share := cents / int64(count)
+	remainder := cents - share*int64(count)
+
+	groupID := primitive.NewObjectID()
+	installments := make([]entities.Transaction, count)
+
+	for i := range installments {
+		installment := t
+		installment.InstallmentGroupID = groupID
+		installment.Installment = i + 1
+		installment.Installments = count
+		installment.Date = AddMonths(t.Date, i)
+		installment.Cost = float64(share) / 100
+
+		if i == 0 {
+			installment.Cost = float64(share+remainder) / 100
+		} else {
+			installment.ID = primitive.NewObjectID()
+			installment.OriginalTaskID += fmt.Sprintf(InstallmentSuffix, i+1)
+		}
+
+		installments[i] = installment
+	}
+
+	return installments
+}
+
+// AddMonths moves the date the given number of months, keeping it in the
+// last day of the month when the day does not exist there, so January 31st
+// is followed by the last day of February instead of early March.
+func AddMonths(date time.Time, months int) time.Time {
+	year, month, day := date.Date()
+
+	first := time.Date(year, month+time.Month(months), 1, 0, 0, 0, 0, date.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+
+	if day > lastDay {
+		day = lastDay
+	}
+
+	return time.Date(
+		first.Year(),
+		first.Month(),
+		day,
+		date.Hour(),
+		date.Minute(),
+		date.Second(),
+		date.Nanosecond(),
+		date.Location(),
+	)
+}
+
+// NewInstallmentPlan builds the plan of the given installments, counting as
+// remaining the ones dated after now.
+func NewInstallmentPlan(
+	groupID primitive.ObjectID,
+	installments []entities.Transaction,
+	now time.Time,
+) *InstallmentPlan {
+	plan := &InstallmentPlan{
+		GroupID:      groupID,
+		Installments: installments,
+	}
+
+	for _, installment := range installments {
+		plan.Description = installment.Description
+		plan.Total += installment.Cost
+
+		if installment.Date.After(now) {
+			plan.Remaining++
+			plan.RemainingValue += installment.Cost
+		}
+	}
+
+	plan.Total = roundCents(plan.Total)
+	plan.RemainingValue = roundCents(plan.RemainingValue)
+
+	return plan
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+func TestSplitInstallments(t *testing.T) {
+	purchase := entities.Transaction{
+		ID:             primitive.NewObjectID(),
+		OriginalTaskID: "task-1",
+		Date:           time.Date(2021, 1, 31, 12, 0, 0, 0, time.UTC),
+		Description:    "TV",
+		Cost:           100,
+	}
+
+	installments := SplitInstallments(purchase, 3)
+	if len(installments) != 3 {
+		t.Fatalf("got %v installments, want 3", len(installments))
+	}
+
+	tests := []struct {
+		taskID string
+		cost   float64
+		date   time.Time
+	}{
+		{taskID: "task-1", cost: 33.34, date: time.Date(2021, 1, 31, 12, 0, 0, 0, time.UTC)},
+		{taskID: "task-1:2", cost: 33.33, date: time.Date(2021, 2, 28, 12, 0, 0, 0, time.UTC)},
+		{taskID: "task-1:3", cost: 33.33, date: time.Date(2021, 3, 31, 12, 0, 0, 0, time.UTC)},
+	}
+
+	for i, test := range tests {
+		got := installments[i]
+
+		if got.OriginalTaskID != test.taskID || got.Cost != test.cost || !got.Date.Equal(test.date) {
+			t.Errorf(
+				"installment %v = %q of %v on %v, want %q of %v on %v",
+				i+1,
+				got.OriginalTaskID,
+				got.Cost,
+				got.Date,
+				test.taskID,
+				test.cost,
+				test.date,
+			)
+		}
+
+		if got.Installment != i+1 || got.Installments != 3 || got.InstallmentGroupID != installments[0].InstallmentGroupID {
+			t.Errorf("installment %v is not linked to the group: %+v", i+1, got)
+		}
+	}
+
+	if installments[0].ID != purchase.ID || installments[1].ID == purchase.ID {
+		t.Errorf("only the first installment must keep the ID of the purchase")
+	}
+}
+
+func TestSplitInstallmentsSingle(t *testing.T) {
+	purchase := entities.Transaction{OriginalTaskID: "task-1", Cost: 10}
+
+	installments := SplitInstallments(purchase, 1)
+	if len(installments) != 1 || installments[0].InstallmentGroupID != primitive.NilObjectID {
+		t.Errorf("got %+v, want the purchase itself", installments)
+	}
+}
+
+// TestSplitInstallmentsAddUp splits random costs from a fixed seed, checking
+// the installments always add up to the original cost, with the remainder
+// in the first one.
+func TestSplitInstallmentsAddUp(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 5000; i++ {
+		count := random.Intn(47) + 2
+		cents := int64(random.Intn(10000000) + count)
+		purchase := entities.Transaction{Cost: float64(cents) / 100}
+
+		var total int64
+		installments := SplitInstallments(purchase, count)
+
+		for _, installment := range installments {
+			total += int64(math.Round(installment.Cost * 100))
+
+			if installment.Cost <= 0 || installment.Cost < installments[len(installments)-1].Cost {
+				t.Fatalf("split %v in %v with the installment %v", purchase.Cost, count, installment.Cost)
+			}
+		}
+
+		first := int64(math.Round(installments[0].Cost * 100))
+		last := int64(math.Round(installments[count-1].Cost * 100))
+
+		if total != cents || first-last >= int64(count) {
+			t.Fatalf("split %v in %v as %+v", purchase.Cost, count, installments)
+		}
+	}
+}
+
+func TestAddMonths(t *testing.T) {
+	tests := []struct {
+		date   time.Time
+		months int
+		want   time.Time
+	}{
+		{date: time.Date(2021, 1, 15, 0, 0, 0, 0, time.UTC), months: 1, want: time.Date(2021, 2, 15, 0, 0, 0, 0, time.UTC)},
+		{date: time.Date(2021, 1, 31, 0, 0, 0, 0, time.UTC), months: 1, want: time.Date(2021, 2, 28, 0, 0, 0, 0, time.UTC)},
+		{date: time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), months: 1, want: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{date: time.Date(2021, 1, 31, 0, 0, 0, 0, time.UTC), months: 2, want: time.Date(2021, 3, 31, 0, 0, 0, 0, time.UTC)},
+		{date: time.Date(2021, 3, 31, 0, 0, 0, 0, time.UTC), months: 1, want: time.Date(2021, 4, 30, 0, 0, 0, 0, time.UTC)},
+		{date: time.Date(2021, 11, 30, 10, 30, 0, 0, time.UTC), months: 3, want: time.Date(2022, 2, 28, 10, 30, 0, 0, time.UTC)},
+		{date: time.Date(2021, 3, 31, 0, 0, 0, 0, time.UTC), months: -1, want: time.Date(2021, 2, 28, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		if got := AddMonths(test.date, test.months); !got.Equal(test.want) {
+			t.Errorf("AddMonths(%v, %v) = %v, want %v", test.date, test.months, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+// RenameRecurringRules moves the rules in any of the old subcategories to
+// the new one, returning the rules that changed, modified at now.
+func RenameRecurringRules(
+	rules []entities.RecurringRule,
+	oldNames []string,
+	newName string,
+	now time.Time,
+) []entities.RecurringRule {
+	var renamed []entities.RecurringRule
+
+	for _, rule := range rules {
+		if !contains(oldNames, rule.Subcategory) {
+			continue
+		}
+
+		rule.Subcategory = newName
+		rule.ModifiedAt = now
+		renamed = append(renamed, rule)
+	}
+
+	return renamed
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
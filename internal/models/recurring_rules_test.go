@@ -0,0 +1,34 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+func TestRenameRecurringRules(t *testing.T) {
+	now := time.Date(2021, 2, 15, 10, 0, 0, 0, time.UTC)
+	rules := []entities.RecurringRule{
+		{Description: "rent", Subcategory: "aluguel"},
+		{Description: "netflix", Subcategory: "streaming"},
+		{Description: "spotify", Subcategory: "musica"},
+	}
+
+	renamed := RenameRecurringRules(rules, []string{"streaming", "musica"}, "assinaturas", now)
+
+	if len(renamed) != 2 {
+		t.Fatalf("renamed %v rules, want 2", len(renamed))
+	}
+
+	for i, want := range []string{"netflix", "spotify"} {
+		got := renamed[i]
+		if got.Description != want || got.Subcategory != "assinaturas" || !got.ModifiedAt.Equal(now) {
+			t.Errorf("rule %v = %+v, want %q in assinaturas modified at %v", i, got, want, now)
+		}
+	}
+
+	if rules[1].Subcategory != "streaming" {
+		t.Errorf("the given rules were changed: %+v", rules)
+	}
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+type StoreResult struct {
+	New             int                 `json:"new"`
+	AlreadyIngested int                 `json:"alreadyIngested"`
+	Updated         int                 `json:"updated"`
+	Changes         []StoredTransaction `json:"-"`
+
+	// TaskIDs lists the task of every transaction that is stored, whether
+	// it was new, updated or already ingested.
+	TaskIDs []string `json:"-"`
+}
+
+// StoredTransaction identifies a transaction that was created or updated,
+// ID being the one it is stored under, which is kept on updates. Previous
+// is how an updated transaction was before.
+type StoredTransaction struct {
+	TaskID   string
+	ID       primitive.ObjectID
+	New      bool
+	Previous *entities.Transaction
+}
+
+func (r StoreResult) Stored() int {
+	return r.New + r.Updated
+}
+
+func (r *StoreResult) Add(other StoreResult) {
+	r.New += other.New
+	r.AlreadyIngested += other.AlreadyIngested
+	r.Updated += other.Updated
+	r.Changes = append(r.Changes, other.Changes...)
+	r.TaskIDs = append(r.TaskIDs, other.TaskIDs...)
+}
+
+// Created records that the transaction was created.
+func (r *StoreResult) Created(t *entities.Transaction) {
+	r.Changes = append(
+		r.Changes,
+		StoredTransaction{TaskID: t.OriginalTaskID, ID: t.ID, New: true},
+	)
+}
+
+// Replaced records that the previous transaction was updated.
+func (r *StoreResult) Replaced(previous entities.Transaction) {
+	r.Changes = append(
+		r.Changes,
+		StoredTransaction{
+			TaskID:   previous.OriginalTaskID,
+			ID:       previous.ID,
+			Previous: &previous,
+		},
+	)
+}
@@ -0,0 +1,202 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+const MonthLayout = "2006-01"
+
+// SummaryGroup totals the transactions of a group. Total, Count and Average
+// only take expenses into account, while Net is the income minus the
+// expenses. Transfers are left out.
+type SummaryGroup struct {
+	Key      string  `json:"key" bson:"_id"`
+	Total    float64 `json:"total" bson:"total"`
+	Count    int64   `json:"count" bson:"count"`
+	Average  float64 `json:"average" bson:"average"`
+	Income   float64 `json:"income" bson:"income"`
+	Expenses float64 `json:"expenses" bson:"expenses"`
+	Net      float64 `json:"net" bson:"net"`
+}
+
+// Totals are the income, expenses and net of a set of transactions.
+type Totals struct {
+	Income   float64 `json:"income" bson:"income"`
+	Expenses float64 `json:"expenses" bson:"expenses"`
+	Net      float64 `json:"net" bson:"net"`
+}
+
+type Summary struct {
+	From          *time.Time     `json:"from,omitempty"`
+	To            *time.Time     `json:"to,omitempty"`
+	Total         SummaryGroup   `json:"total"`
+	ByMonth       []SummaryGroup `json:"byMonth"`
+	BySubcategory []SummaryGroup `json:"bySubcategory"`
+	ByCategory    []SummaryGroup `json:"byCategory"`
+}
+
+type SummaryComparison struct {
+	Previous      *Summary `json:"previous"`
+	Change        float64  `json:"change"`
+	ChangePercent *float64 `json:"changePercent"`
+}
+
+// Summarize groups the given transactions the same way the database
+// aggregation does, for storages that cannot aggregate by themselves.
+func Summarize(transactions []entities.Transaction) *Summary {
+	total := &SummaryGroup{}
+	byMonth := make(map[string]*SummaryGroup)
+	bySubcategory := make(map[string]*SummaryGroup)
+	byCategory := make(map[string]*SummaryGroup)
+
+	for _, t := range transactions {
+		if !t.IsIncome() && !t.IsExpense() {
+			continue
+		}
+
+		addToGroup(total, &t)
+		addToGroup(groupOf(byMonth, t.Date.UTC().Format(MonthLayout)), &t)
+		addToGroup(groupOf(bySubcategory, t.Subcategory), &t)
+		addToGroup(groupOf(byCategory, t.Category), &t)
+	}
+
+	summary := &Summary{
+		Total:         *total,
+		ByMonth:       sortedGroups(byMonth),
+		BySubcategory: sortedGroups(bySubcategory),
+		ByCategory:    sortedGroups(byCategory),
+	}
+
+	summary.Total.Key = ""
+	summary.completeGroups()
+
+	sort.Slice(summary.ByMonth, func(i, j int) bool {
+		return summary.ByMonth[i].Key < summary.ByMonth[j].Key
+	})
+
+	return summary
+}
+
+// Round rounds every value of the summary to cents.
+func (s *Summary) Round() {
+	roundGroup(&s.Total)
+
+	for _, groups := range [][]SummaryGroup{s.ByMonth, s.BySubcategory, s.ByCategory} {
+		for i := range groups {
+			roundGroup(&groups[i])
+		}
+	}
+}
+
+func (s *Summary) Compare(previous *Summary) *SummaryComparison {
+	comparison := &SummaryComparison{
+		Previous: previous,
+		Change:   roundCents(s.Total.Total - previous.Total.Total),
+	}
+
+	if previous.Total.Total != 0 {
+		percent := roundCents(comparison.Change / previous.Total.Total * 100)
+		comparison.ChangePercent = &percent
+	}
+
+	return comparison
+}
+
+func (s *Summary) completeGroups() {
+	completeGroup(&s.Total)
+
+	for _, groups := range [][]SummaryGroup{s.ByMonth, s.BySubcategory, s.ByCategory} {
+		for i := range groups {
+			completeGroup(&groups[i])
+		}
+	}
+}
+
+func addToGroup(group *SummaryGroup, t *entities.Transaction) {
+	if t.IsIncome() {
+		group.Income += t.Cost
+		return
+	}
+
+	group.Total += t.Cost
+	group.Count++
+}
+
+func groupOf(groups map[string]*SummaryGroup, key string) *SummaryGroup {
+	group, found := groups[key]
+	if !found {
+		group = &SummaryGroup{Key: key}
+		groups[key] = group
+	}
+
+	return group
+}
+
+func sortedGroups(groups map[string]*SummaryGroup) []SummaryGroup {
+	sorted := make([]SummaryGroup, 0, len(groups))
+	for _, group := range groups {
+		sorted = append(sorted, *group)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Total != sorted[j].Total {
+			return sorted[i].Total > sorted[j].Total
+		}
+
+		return sorted[i].Key < sorted[j].Key
+	})
+
+	return sorted
+}
+
+func completeGroup(group *SummaryGroup) {
+	if group.Count > 0 {
+		group.Average = group.Total / float64(group.Count)
+	}
+
+	group.Expenses = group.Total
+	group.Net = group.Income - group.Total
+}
+
+func roundGroup(group *SummaryGroup) {
+	group.Total = roundCents(group.Total)
+	group.Average = roundCents(group.Average)
+	group.Income = roundCents(group.Income)
+	group.Expenses = roundCents(group.Expenses)
+	group.Net = roundCents(group.Net)
+}
+
+// TotalsOf adds up the income and expenses of the given transactions, for
+// storages that cannot aggregate by themselves.
+func TotalsOf(transactions []entities.Transaction) Totals {
+	var totals Totals
+
+	for _, t := range transactions {
+		switch {
+		case t.IsIncome():
+			totals.Income += t.Cost
+		case t.IsExpense():
+			totals.Expenses += t.Cost
+		}
+	}
+
+	totals.Net = totals.Income - totals.Expenses
+	totals.Round()
+
+	return totals
+}
+
+// Round rounds every value of the totals to cents.
+func (t *Totals) Round() {
+	t.Income = roundCents(t.Income)
+	t.Expenses = roundCents(t.Expenses)
+	t.Net = roundCents(t.Net)
+}
+
+func roundCents(value float64) float64 {
+	return math.Round(value*100) / 100
+}
@@ -10,4 +10,10 @@ type Task struct {
 	Importance   string    `json:"importance"`
 	IsReminderOn bool      `json:"isReminderOn"`
 	Status       string    `json:"status"`
+	Body         TaskBody  `json:"body"`
+}
+
+type TaskBody struct {
+	Content     string `json:"content"`
+	ContentType string `json:"contentType"`
 }
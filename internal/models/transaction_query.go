@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+// Fields transactions can be sorted by
+const (
+	SortByDate        = "date"
+	SortByValue       = "value"
+	SortByDescription = "description"
+	SortBySubcategory = "subcategory"
+	SortByCreatedAt   = "createdAt"
+)
+
+var SortFields = []string{
+	SortByDate,
+	SortByValue,
+	SortByDescription,
+	SortBySubcategory,
+	SortByCreatedAt,
+}
+
+// TransactionQuery holds the filters of a transaction search. Zero values
+// mean the filter is not applied, and a zero PageSize returns every match.
+// From is inclusive while To is exclusive. Deleted transactions only match
+// when IncludeDeleted is set.
+type TransactionQuery struct {
+	From               time.Time
+	To                 time.Time
+	MinValue           *float64
+	MaxValue           *float64
+	Description        string
+	Subcategory        string
+	AccountID          primitive.ObjectID
+	Direction          string
+	InstallmentGroupID primitive.ObjectID
+	TransferID         primitive.ObjectID
+	IncludeDeleted     bool
+	Sort               string
+	Descending         bool
+	Page               int
+	PageSize           int
+}
+
+func (q *TransactionQuery) Skip() int {
+	if q.PageSize == 0 || q.Page <= 1 {
+		return 0
+	}
+
+	return (q.Page - 1) * q.PageSize
+}
+
+type TransactionPage struct {
+	Transactions []entities.Transaction `json:"transactions"`
+	Total        int64                  `json:"total"`
+	Totals       Totals                 `json:"totals"`
+	Page         int                    `json:"page,omitempty"`
+	PageSize     int                    `json:"pageSize,omitempty"`
+}
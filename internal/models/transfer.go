@@ -0,0 +1,46 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+	"github.com/jbonadiman/finances-api/internal/parser"
+)
+
+// TransferInSuffix keys the arriving side of a transfer on the task ID, since
+// both sides come from the same task.
+const TransferInSuffix = ":in"
+
+// SplitTransfer splits a transfer into the transaction leaving its account
+// and the one arriving at the to account, linked by the transfer ID. Both
+// accounts must be among the account IDs, by name, as the balance of each
+// one changes.
+func SplitTransfer(
+	out entities.Transaction,
+	to string,
+	accountIDs map[string]primitive.ObjectID,
+) ([]entities.Transaction, error) {
+	for _, account := range []string{out.Account, to} {
+		if _, found := accountIDs[account]; !found {
+			return nil, &parser.FieldError{
+				Field:  "account",
+				Value:  account,
+				Reason: "could not find an account with this name to transfer between",
+			}
+		}
+	}
+
+	out.Direction = entities.DirectionTransfer
+	out.AccountID = accountIDs[out.Account]
+	out.TransferID = primitive.NewObjectID()
+	out.TransferSide = entities.TransferOut
+
+	in := out
+	in.ID = primitive.NewObjectID()
+	in.OriginalTaskID = out.OriginalTaskID + TransferInSuffix
+	in.TransferSide = entities.TransferIn
+	in.Account = to
+	in.AccountID = accountIDs[to]
+
+	return []entities.Transaction{out, in}, nil
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+	"github.com/jbonadiman/finances-api/internal/parser"
+)
+
+func TestSplitTransfer(t *testing.T) {
+	accountIDs := map[string]primitive.ObjectID{
+		"itau":   primitive.NewObjectID(),
+		"nubank": primitive.NewObjectID(),
+	}
+
+	out := entities.Transaction{
+		ID:             primitive.NewObjectID(),
+		OriginalTaskID: "task-1",
+		Description:    "credit card bill",
+		Cost:           1200,
+		Direction:      entities.DirectionExpense,
+		Account:        "itau",
+	}
+
+	sides, err := SplitTransfer(out, "nubank", accountIDs)
+	if err != nil {
+		t.Fatalf("SplitTransfer returned an error: %v", err)
+	}
+
+	if len(sides) != 2 {
+		t.Fatalf("got %v sides, want 2", len(sides))
+	}
+
+	tests := []struct {
+		side    string
+		taskID  string
+		account string
+		change  float64
+	}{
+		{side: entities.TransferOut, taskID: "task-1", account: "itau", change: -1200},
+		{side: entities.TransferIn, taskID: "task-1" + TransferInSuffix, account: "nubank", change: 1200},
+	}
+
+	for i, test := range tests {
+		got := sides[i]
+
+		if got.TransferSide != test.side ||
+			got.OriginalTaskID != test.taskID ||
+			got.Account != test.account ||
+			got.AccountID != accountIDs[test.account] ||
+			got.BalanceChange() != test.change {
+			t.Errorf("side %v = %+v, want %v %q of %v in %v", i, got, test.side, test.taskID, test.change, test.account)
+		}
+
+		if !got.IsTransfer() || got.TransferID.IsZero() || got.TransferID != sides[0].TransferID {
+			t.Errorf("side %v is not linked to the transfer: %+v", i, got)
+		}
+	}
+
+	if sides[0].ID != out.ID || sides[1].ID == out.ID {
+		t.Errorf("got the IDs %v and %v, want %v and a new one", sides[0].ID, sides[1].ID, out.ID)
+	}
+}
+
+func TestSplitTransferUnknownAccount(t *testing.T) {
+	accountIDs := map[string]primitive.ObjectID{"itau": primitive.NewObjectID()}
+
+	tests := []struct {
+		from string
+		to   string
+	}{
+		{from: "itau", to: "nubank"},
+		{from: "nubank", to: "itau"},
+		{from: "", to: "itau"},
+	}
+
+	for _, test := range tests {
+		out := entities.Transaction{OriginalTaskID: "task-1", Cost: 10, Account: test.from}
+
+		_, err := SplitTransfer(out, test.to, accountIDs)
+
+		var fieldErr *parser.FieldError
+		if !errors.As(err, &fieldErr) || fieldErr.Field != "account" {
+			t.Fatalf("%v>%v: got error %v, want an account error", test.from, test.to, err)
+		}
+
+		unknown := test.to
+		if _, found := accountIDs[test.from]; !found {
+			unknown = test.from
+		}
+
+		if fieldErr.Value != unknown {
+			t.Errorf("%v>%v: got the account %q, want %q", test.from, test.to, fieldErr.Value, unknown)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+// Package parser reads transactions out of To Do task titles.
+//
+// A title is made of fields separated by semicolons:
+//
+//	cost;description[;category[;extras...]]
+//
+// The cost and the description are required. A cost with a leading plus
+// sign, such as "+3500", is income instead of an expense. The category may
+// be left out or empty, in which case it is guessed from the description.
+// A category naming two accounts, such as "itau>nubank", makes the title a
+// transfer of the cost from the first account to the second one instead.
+// Any field after the category holds whitespace separated extras, in any
+// order:
+//
+//	2021-02-15 or 15/02/2021  the date of the transaction
+//	@account                  the account or payment method used
+//	#tag                      a tag, may be repeated
+//	USD                       a three letter currency code
+//	12x                       the number of monthly installments
+//
+// For example: "42.50;pizza;delivery;15/02/2021 @nubank #weekend BRL",
+// "+3500;salary;salario;@itau", "1200;credit card bill;itau>nubank" or
+// "1200;TV;eletronicos;12x".
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FieldSeparator    = ";"
+	IncomePrefix      = "+"
+	TransferSeparator = ">"
+
+	MaxInstallments = 48
+
+	accountPrefix      = "@"
+	tagPrefix          = "#"
+	installmentsSuffix = "x"
+)
+
+var dateLayouts = []string{
+	"2006-01-02",
+	"02/01/2006",
+}
+
+type Transaction struct {
+	Cost         float64
+	Income       bool
+	Description  string
+	Category     string
+	Date         time.Time
+	Account      string
+	TransferTo   string
+	Installments int
+	Tags         []string
+	Currency     string
+}
+
+// FieldError describes why a single field of a title could not be parsed.
+type FieldError struct {
+	Field  string
+	Value  string
+	Reason string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("invalid %v %q: %v", e.Field, e.Value, e.Reason)
+}
+
+func Parse(title string) (*Transaction, error) {
+	fields := strings.Split(title, FieldSeparator)
+
+	if len(fields) < 2 {
+		return nil, &FieldError{
+			Field:  "title",
+			Value:  title,
+			Reason: "a transaction must be composed of at least two parts: one for the cost and one for the description, optionally followed by the category",
+		}
+	}
+
+	costField := strings.TrimSpace(fields[0])
+	income := strings.HasPrefix(costField, IncomePrefix)
+
+	cost, err := parseCost(strings.TrimPrefix(costField, IncomePrefix))
+	if err != nil {
+		return nil, err
+	}
+
+	description := strings.TrimSpace(fields[1])
+	if description == "" {
+		return nil, &FieldError{
+			Field:  "description",
+			Value:  fields[1],
+			Reason: "must not be empty",
+		}
+	}
+
+	transaction := &Transaction{
+		Cost:        cost,
+		Income:      income,
+		Description: description,
+	}
+
+	if len(fields) < 3 {
+		return transaction, nil
+	}
+
+	category := strings.TrimSpace(fields[2])
+	if strings.Contains(category, TransferSeparator) {
+		err = transaction.parseTransfer(category)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		transaction.Category = category
+	}
+
+	for _, field := range fields[3:] {
+		for _, token := range strings.Fields(field) {
+			err = transaction.parseExtra(token)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return transaction, nil
+}
+
+func parseCost(field string) (float64, error) {
+	value := strings.TrimSpace(field)
+
+	cost, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(cost) || math.IsInf(cost, 0) {
+		return 0, &FieldError{
+			Field:  "cost",
+			Value:  value,
+			Reason: "must be a number",
+		}
+	}
+
+	if cost <= 0 {
+		return 0, &FieldError{
+			Field:  "cost",
+			Value:  value,
+			Reason: "must be greater than zero",
+		}
+	}
+
+	return cost, nil
+}
+
+// IsTransfer reports whether the title moves money from Account to
+// TransferTo.
+func (t *Transaction) IsTransfer() bool {
+	return t.TransferTo != ""
+}
+
+func (t *Transaction) parseTransfer(field string) error {
+	if t.Income {
+		return &FieldError{
+			Field:  "category",
+			Value:  field,
+			Reason: "a transfer cannot be income",
+		}
+	}
+
+	accounts := strings.Split(field, TransferSeparator)
+	if len(accounts) != 2 {
+		return &FieldError{
+			Field:  "category",
+			Value:  field,
+			Reason: "a transfer must name exactly two accounts, as in itau>nubank",
+		}
+	}
+
+	from := strings.TrimPrefix(strings.TrimSpace(accounts[0]), accountPrefix)
+	to := strings.TrimPrefix(strings.TrimSpace(accounts[1]), accountPrefix)
+
+	if from == "" || to == "" {
+		return &FieldError{
+			Field:  "category",
+			Value:  field,
+			Reason: "both accounts of a transfer must have a name",
+		}
+	}
+
+	if len(strings.Fields(from)) > 1 || len(strings.Fields(to)) > 1 {
+		return &FieldError{
+			Field:  "category",
+			Value:  field,
+			Reason: "the accounts of a transfer must not contain spaces",
+		}
+	}
+
+	if from == to {
+		return &FieldError{
+			Field:  "category",
+			Value:  field,
+			Reason: "a transfer must be between different accounts",
+		}
+	}
+
+	t.Account, t.TransferTo = from, to
+	return nil
+}
+
+func (t *Transaction) parseExtra(token string) error {
+	switch {
+	case strings.HasPrefix(token, accountPrefix):
+		return t.parseAccount(token)
+	case strings.HasPrefix(token, tagPrefix):
+		return t.parseTag(token)
+	case IsCurrency(token):
+		return t.parseCurrency(token)
+	case isInstallments(token):
+		return t.parseInstallments(token)
+	default:
+		return t.parseDate(token)
+	}
+}
+
+func (t *Transaction) parseAccount(token string) error {
+	account := strings.TrimPrefix(token, accountPrefix)
+
+	if account == "" {
+		return &FieldError{Field: "account", Value: token, Reason: "must have a name"}
+	}
+
+	if t.Account != "" {
+		return &FieldError{
+			Field:  "account",
+			Value:  token,
+			Reason: fmt.Sprintf("account already set to %q", t.Account),
+		}
+	}
+
+	t.Account = account
+	return nil
+}
+
+func (t *Transaction) parseTag(token string) error {
+	tag := strings.TrimPrefix(token, tagPrefix)
+
+	if tag == "" {
+		return &FieldError{Field: "tag", Value: token, Reason: "must have a name"}
+	}
+
+	for _, existing := range t.Tags {
+		if existing == tag {
+			return nil
+		}
+	}
+
+	t.Tags = append(t.Tags, tag)
+	return nil
+}
+
+func (t *Transaction) parseCurrency(token string) error {
+	if t.Currency != "" {
+		return &FieldError{
+			Field:  "currency",
+			Value:  token,
+			Reason: fmt.Sprintf("currency already set to %q", t.Currency),
+		}
+	}
+
+	t.Currency = token
+	return nil
+}
+
+func (t *Transaction) parseInstallments(token string) error {
+	if t.Installments != 0 {
+		return &FieldError{
+			Field:  "installments",
+			Value:  token,
+			Reason: fmt.Sprintf("installments already set to %v", t.Installments),
+		}
+	}
+
+	if t.IsTransfer() {
+		return &FieldError{
+			Field:  "installments",
+			Value:  token,
+			Reason: "a transfer cannot be paid in installments",
+		}
+	}
+
+	count, _ := strconv.Atoi(strings.TrimSuffix(token, installmentsSuffix))
+	if count < 1 || count > MaxInstallments {
+		return &FieldError{
+			Field:  "installments",
+			Value:  token,
+			Reason: fmt.Sprintf("must be between 1 and %v", MaxInstallments),
+		}
+	}
+
+	if int64(math.Round(t.Cost*100)) < int64(count) {
+		return &FieldError{
+			Field:  "installments",
+			Value:  token,
+			Reason: "every installment must be at least one cent",
+		}
+	}
+
+	t.Installments = count
+	return nil
+}
+
+func (t *Transaction) parseDate(token string) error {
+	for _, layout := range dateLayouts {
+		date, err := time.Parse(layout, token)
+		if err != nil {
+			continue
+		}
+
+		if !t.Date.IsZero() {
+			return &FieldError{
+				Field:  "date",
+				Value:  token,
+				Reason: fmt.Sprintf("date already set to %v", t.Date.Format(dateLayouts[0])),
+			}
+		}
+
+		t.Date = date
+		return nil
+	}
+
+	return &FieldError{
+		Field:  "extra",
+		Value:  token,
+		Reason: "expected a date (2021-02-15 or 15/02/2021), an @account, a #tag, a currency code or installments (12x)",
+	}
+}
+
+func isInstallments(token string) bool {
+	count := strings.TrimSuffix(token, installmentsSuffix)
+	if count == token || count == "" {
+		return false
+	}
+
+	for _, r := range count {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsCurrency reports whether the token is a three letter currency code.
+func IsCurrency(token string) bool {
+	if len(token) != 3 {
+		return false
+	}
+
+	for _, r := range token {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,296 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		title string
+		want  Transaction
+	}{
+		{
+			title: "42.50;pizza",
+			want:  Transaction{Cost: 42.5, Description: "pizza"},
+		},
+		{
+			title: "42.50;pizza;delivery",
+			want:  Transaction{Cost: 42.5, Description: "pizza", Category: "delivery"},
+		},
+		{
+			title: " 42.50 ; pizza ; delivery ",
+			want:  Transaction{Cost: 42.5, Description: "pizza", Category: "delivery"},
+		},
+		{
+			title: "42.50;pizza;",
+			want:  Transaction{Cost: 42.5, Description: "pizza"},
+		},
+		{
+			title: "+3500;salary;salario;@itau",
+			want: Transaction{
+				Cost:        3500,
+				Income:      true,
+				Description: "salary",
+				Category:    "salario",
+				Account:     "itau",
+			},
+		},
+		{
+			title: "42.50;pizza;delivery;15/02/2021 @nubank #weekend BRL",
+			want: Transaction{
+				Cost:        42.5,
+				Description: "pizza",
+				Category:    "delivery",
+				Date:        time.Date(2021, 2, 15, 0, 0, 0, 0, time.UTC),
+				Account:     "nubank",
+				Tags:        []string{"weekend"},
+				Currency:    "BRL",
+			},
+		},
+		{
+			title: "10;coffee;;2021-02-15;#work #work #late",
+			want: Transaction{
+				Cost:        10,
+				Description: "coffee",
+				Date:        time.Date(2021, 2, 15, 0, 0, 0, 0, time.UTC),
+				Tags:        []string{"work", "late"},
+			},
+		},
+		{
+			title: "1200;credit card bill;itau>nubank",
+			want: Transaction{
+				Cost:        1200,
+				Description: "credit card bill",
+				Account:     "itau",
+				TransferTo:  "nubank",
+			},
+		},
+		{
+			title: "1200;credit card bill; @itau > @nubank ",
+			want: Transaction{
+				Cost:        1200,
+				Description: "credit card bill",
+				Account:     "itau",
+				TransferTo:  "nubank",
+			},
+		},
+		{
+			title: "0.12;gum;mercado;12x",
+			want: Transaction{
+				Cost:         0.12,
+				Description:  "gum",
+				Category:     "mercado",
+				Installments: 12,
+			},
+		},
+		{
+			title: "1200;TV;eletronicos;12x",
+			want: Transaction{
+				Cost:         1200,
+				Description:  "TV",
+				Category:     "eletronicos",
+				Installments: 12,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			got, err := Parse(test.title)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned an error: %v", test.title, err)
+			}
+
+			if !reflect.DeepEqual(*got, test.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", test.title, *got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseFieldErrors(t *testing.T) {
+	tests := []struct {
+		title string
+		field string
+		value string
+	}{
+		{title: "", field: "title", value: ""},
+		{title: "42.50", field: "title", value: "42.50"},
+		{title: "abc;pizza", field: "cost", value: "abc"},
+		{title: ";pizza", field: "cost", value: ""},
+		{title: "+;pizza", field: "cost", value: ""},
+		{title: "NaN;pizza", field: "cost", value: "NaN"},
+		{title: "Inf;pizza", field: "cost", value: "Inf"},
+		{title: "+Infinity;pizza", field: "cost", value: "Infinity"},
+		{title: "-Inf;pizza", field: "cost", value: "-Inf"},
+		{title: "1e400;pizza", field: "cost", value: "1e400"},
+		{title: "0;pizza", field: "cost", value: "0"},
+		{title: "-10;pizza", field: "cost", value: "-10"},
+		{title: "10; ;delivery", field: "description", value: " "},
+		{title: "+10;salary;itau>nubank", field: "category", value: "itau>nubank"},
+		{title: "10;bill;itau>nubank>inter", field: "category", value: "itau>nubank>inter"},
+		{title: "10;bill;itau>", field: "category", value: "itau>"},
+		{title: "10;bill;>@nubank", field: "category", value: ">@nubank"},
+		{title: "10;bill;itau bank>nubank", field: "category", value: "itau bank>nubank"},
+		{title: "10;bill;itau>itau", field: "category", value: "itau>itau"},
+		{title: "10;pizza;delivery;@", field: "account", value: "@"},
+		{title: "10;pizza;delivery;@itau @nubank", field: "account", value: "@nubank"},
+		{title: "10;bill;itau>nubank;@inter", field: "account", value: "@inter"},
+		{title: "10;pizza;delivery;#", field: "tag", value: "#"},
+		{title: "10;pizza;delivery;BRL;USD", field: "currency", value: "USD"},
+		{title: "10;tv;eletronicos;2x 3x", field: "installments", value: "3x"},
+		{title: "10;bill;itau>nubank;2x", field: "installments", value: "2x"},
+		{title: "10;tv;eletronicos;0x", field: "installments", value: "0x"},
+		{title: "10;tv;eletronicos;49x", field: "installments", value: "49x"},
+		{title: "0.05;tv;eletronicos;12x", field: "installments", value: "12x"},
+		{title: "10;pizza;delivery;2021-02-15 15/02/2021", field: "date", value: "15/02/2021"},
+		{title: "10;pizza;delivery;2021-02-30", field: "extra", value: "2021-02-30"},
+		{title: "10;pizza;delivery;brl", field: "extra", value: "brl"},
+		{title: "10;pizza;delivery;x", field: "extra", value: "x"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			got, err := Parse(test.title)
+			if err == nil {
+				t.Fatalf("Parse(%q) = %+v, want a %v error", test.title, *got, test.field)
+			}
+
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) {
+				t.Fatalf("Parse(%q) returned %T, want *FieldError", test.title, err)
+			}
+
+			if fieldErr.Field != test.field || fieldErr.Value != test.value {
+				t.Errorf(
+					"Parse(%q) failed on %v %q, want %v %q",
+					test.title,
+					fieldErr.Field,
+					fieldErr.Value,
+					test.field,
+					test.value,
+				)
+			}
+		})
+	}
+}
+
+// fragments titles are randomly assembled from, mixing valid and invalid
+// fields so both kinds of outcome are exercised
+var fragments = []string{
+	";", ";", ";", " ", "+", "-", ">", "@", "#", "x", ".", "0", "1", "42.50",
+	"1e400", "NaN", "Inf", "pizza", "itau", "nubank", "BRL", "brl", "12x",
+	"49x", "2021-02-15", "15/02/2021", "2021-02-30", "\t", "ç",
+}
+
+// TestParseProperties parses random titles from a fixed seed, checking the
+// invariants every outcome must hold: errors are always field errors and
+// parsed transactions are always valid.
+func TestParseProperties(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 20000; i++ {
+		var title strings.Builder
+		for n := random.Intn(12); n >= 0; n-- {
+			title.WriteString(fragments[random.Intn(len(fragments))])
+		}
+
+		checkParse(t, title.String())
+	}
+}
+
+func checkParse(t *testing.T, title string) {
+	t.Helper()
+
+	got, err := Parse(title)
+	if err != nil {
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) {
+			t.Fatalf("Parse(%q) returned %T, want *FieldError", title, err)
+		}
+
+		return
+	}
+
+	switch {
+	case math.IsNaN(got.Cost) || math.IsInf(got.Cost, 0) || got.Cost <= 0:
+		t.Fatalf("Parse(%q) accepted the cost %v", title, got.Cost)
+	case got.Description == "" || got.Description != strings.TrimSpace(got.Description):
+		t.Fatalf("Parse(%q) accepted the description %q", title, got.Description)
+	case got.Installments < 0 || got.Installments > MaxInstallments ||
+		math.Round(got.Cost*100) < float64(got.Installments):
+		t.Fatalf("Parse(%q) accepted %v installments", title, got.Installments)
+	case got.IsTransfer() && (got.Income || got.Installments != 0 || got.Account == got.TransferTo):
+		t.Fatalf("Parse(%q) accepted the transfer %+v", title, *got)
+	case got.Currency != "" && !IsCurrency(got.Currency):
+		t.Fatalf("Parse(%q) accepted the currency %q", title, got.Currency)
+	}
+}
+
+// TestParseRoundTrip formats random valid transactions as titles and checks
+// they are parsed back as they were.
+func TestParseRoundTrip(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	names := []string{"pizza", "itau", "nubank", "work", "salary", "café"}
+
+	for i := 0; i < 5000; i++ {
+		want := Transaction{
+			Cost:        float64(random.Intn(1000000)+MaxInstallments) / 100,
+			Income:      random.Intn(2) == 0,
+			Description: names[random.Intn(len(names))],
+			Category:    names[random.Intn(len(names))],
+		}
+
+		var extras []string
+		if random.Intn(2) == 0 {
+			want.Date = time.Date(2000+random.Intn(30), time.Month(random.Intn(12)+1), random.Intn(28)+1, 0, 0, 0, 0, time.UTC)
+			extras = append(extras, want.Date.Format(dateLayouts[random.Intn(len(dateLayouts))]))
+		}
+		if random.Intn(2) == 0 {
+			want.Account = names[random.Intn(len(names))]
+			extras = append(extras, accountPrefix+want.Account)
+		}
+		if random.Intn(2) == 0 {
+			want.Tags = []string{names[random.Intn(len(names))]}
+			extras = append(extras, tagPrefix+want.Tags[0])
+		}
+		if random.Intn(2) == 0 {
+			want.Currency = "USD"
+			extras = append(extras, want.Currency)
+		}
+		if random.Intn(2) == 0 {
+			want.Installments = random.Intn(MaxInstallments) + 1
+			extras = append(extras, fmt.Sprintf("%v%v", want.Installments, installmentsSuffix))
+		}
+
+		random.Shuffle(len(extras), func(i, j int) {
+			extras[i], extras[j] = extras[j], extras[i]
+		})
+
+		cost := fmt.Sprintf("%.2f", want.Cost)
+		if want.Income {
+			cost = IncomePrefix + cost
+		}
+
+		title := strings.Join(
+			[]string{cost, want.Description, want.Category, strings.Join(extras, " ")},
+			FieldSeparator,
+		)
+
+		got, err := Parse(title)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned an error: %v", title, err)
+		}
+
+		if !reflect.DeepEqual(*got, want) {
+			t.Fatalf("Parse(%q) = %+v, want %+v", title, *got, want)
+		}
+	}
+}
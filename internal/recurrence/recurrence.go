@@ -0,0 +1,219 @@
+// Package recurrence reads the schedules of recurring transactions, a subset
+// of the iCalendar RRULE syntax made of semicolon separated KEY=VALUE parts:
+//
+//	FREQ        DAILY, WEEKLY, MONTHLY or YEARLY, required
+//	INTERVAL    repeat every n periods, 1 by default
+//	BYDAY       weekly only, comma separated days (MO,TU,WE,TH,FR,SA,SU)
+//	BYMONTHDAY  monthly only, the day of the month, negative counting from
+//	            its end (-1 is the last day)
+//
+// For example "FREQ=MONTHLY;BYMONTHDAY=5" or "FREQ=WEEKLY;INTERVAL=2;BYDAY=FR".
+// Occurrences are counted from the start of the rule, which also sets their
+// time of the day. Days missing from a month fall on its last day.
+package recurrence
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jbonadiman/finances-api/internal/models"
+)
+
+// Frequencies of a schedule
+const (
+	Daily   = "DAILY"
+	Weekly  = "WEEKLY"
+	Monthly = "MONTHLY"
+	Yearly  = "YEARLY"
+)
+
+// maxPeriods stops schedules that never reach the end of a search
+const maxPeriods = 100000
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+type Schedule struct {
+	Frequency  string
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay int
+}
+
+// ScheduleError describes why a schedule could not be parsed.
+type ScheduleError struct {
+	Part   string
+	Reason string
+}
+
+func (e *ScheduleError) Error() string {
+	return fmt.Sprintf("invalid schedule part %q: %v", e.Part, e.Reason)
+}
+
+func Parse(rule string) (*Schedule, error) {
+	schedule := &Schedule{Interval: 1}
+
+	for _, part := range strings.Split(strings.ToUpper(rule), ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		pair := strings.SplitN(part, "=", 2)
+		if len(pair) != 2 {
+			return nil, &ScheduleError{Part: part, Reason: "expected KEY=VALUE"}
+		}
+
+		err := schedule.parsePart(pair[0], pair[1])
+		if err != nil {
+			return nil, &ScheduleError{Part: part, Reason: err.Error()}
+		}
+	}
+
+	switch {
+	case schedule.Frequency == "":
+		return nil, &ScheduleError{Part: rule, Reason: "FREQ is required"}
+	case len(schedule.ByDay) > 0 && schedule.Frequency != Weekly:
+		return nil, &ScheduleError{Part: rule, Reason: "BYDAY is only allowed with FREQ=WEEKLY"}
+	case schedule.ByMonthDay != 0 && schedule.Frequency != Monthly:
+		return nil, &ScheduleError{Part: rule, Reason: "BYMONTHDAY is only allowed with FREQ=MONTHLY"}
+	}
+
+	return schedule, nil
+}
+
+func (s *Schedule) parsePart(key, value string) error {
+	switch key {
+	case "FREQ":
+		switch value {
+		case Daily, Weekly, Monthly, Yearly:
+			s.Frequency = value
+			return nil
+		}
+
+		return fmt.Errorf("must be %v, %v, %v or %v", Daily, Weekly, Monthly, Yearly)
+	case "INTERVAL":
+		interval, err := strconv.Atoi(value)
+		if err != nil || interval < 1 {
+			return fmt.Errorf("must be a positive integer")
+		}
+
+		s.Interval = interval
+		return nil
+	case "BYDAY":
+		for _, day := range strings.Split(value, ",") {
+			weekday, found := weekdays[strings.TrimSpace(day)]
+			if !found {
+				return fmt.Errorf("unknown day %q", day)
+			}
+
+			s.ByDay = append(s.ByDay, weekday)
+		}
+
+		sort.Slice(s.ByDay, func(i, j int) bool {
+			return mondayOffset(s.ByDay[i]) < mondayOffset(s.ByDay[j])
+		})
+		return nil
+	case "BYMONTHDAY":
+		day, err := strconv.Atoi(value)
+		if err != nil || day == 0 || day < -31 || day > 31 {
+			return fmt.Errorf("must be between 1 and 31, or -31 and -1")
+		}
+
+		s.ByMonthDay = day
+		return nil
+	default:
+		return fmt.Errorf("unsupported key %q", key)
+	}
+}
+
+// Between returns the occurrences of the schedule starting at start that
+// fall after the after time and up to the until time, in order.
+func (s *Schedule) Between(start, after, until time.Time) []time.Time {
+	var occurrences []time.Time
+
+	for period := 0; period < maxPeriods; period++ {
+		for _, occurrence := range s.period(start, period) {
+			if occurrence.Before(start) {
+				continue
+			}
+
+			if occurrence.After(until) {
+				return occurrences
+			}
+
+			if occurrence.After(after) {
+				occurrences = append(occurrences, occurrence)
+			}
+		}
+	}
+
+	return occurrences
+}
+
+// period returns the occurrences of the nth period after the start, in
+// order.
+func (s *Schedule) period(start time.Time, n int) []time.Time {
+	switch s.Frequency {
+	case Daily:
+		return []time.Time{start.AddDate(0, 0, n*s.Interval)}
+	case Weekly:
+		week := start.AddDate(0, 0, 7*n*s.Interval)
+		if len(s.ByDay) == 0 {
+			return []time.Time{week}
+		}
+
+		monday := week.AddDate(0, 0, -mondayOffset(week.Weekday()))
+
+		occurrences := make([]time.Time, len(s.ByDay))
+		for i, day := range s.ByDay {
+			occurrences[i] = monday.AddDate(0, 0, mondayOffset(day))
+		}
+
+		return occurrences
+	case Monthly:
+		month := models.AddMonths(start, n*s.Interval)
+		if s.ByMonthDay == 0 {
+			return []time.Time{month}
+		}
+
+		return []time.Time{dayOfMonth(month, s.ByMonthDay)}
+	default:
+		return []time.Time{models.AddMonths(start, 12*n*s.Interval)}
+	}
+}
+
+func mondayOffset(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}
+
+// dayOfMonth moves the date to the given day of its month, counting from the
+// end of the month when negative and staying within the month.
+func dayOfMonth(date time.Time, day int) time.Time {
+	first := date.AddDate(0, 0, 1-date.Day())
+	lastDay := first.AddDate(0, 1, -1).Day()
+
+	if day < 0 {
+		day = lastDay + day + 1
+	}
+
+	if day < 1 {
+		day = 1
+	}
+
+	if day > lastDay {
+		day = lastDay
+	}
+
+	return first.AddDate(0, 0, day-1)
+}
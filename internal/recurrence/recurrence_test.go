@@ -0,0 +1,159 @@
+package recurrence
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		rule string
+		want Schedule
+	}{
+		{rule: "FREQ=DAILY", want: Schedule{Frequency: Daily, Interval: 1}},
+		{rule: "freq=monthly; bymonthday=-1;", want: Schedule{Frequency: Monthly, Interval: 1, ByMonthDay: -1}},
+		{rule: "FREQ=YEARLY;INTERVAL=2", want: Schedule{Frequency: Yearly, Interval: 2}},
+		{
+			rule: "FREQ=WEEKLY;BYDAY=SU,FR,MO",
+			want: Schedule{
+				Frequency: Weekly,
+				Interval:  1,
+				ByDay:     []time.Weekday{time.Monday, time.Friday, time.Sunday},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		got, err := Parse(test.rule)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned an error: %v", test.rule, err)
+		}
+
+		if !reflect.DeepEqual(*got, test.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", test.rule, *got, test.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	rules := []string{
+		"",
+		"FREQ",
+		"FREQ=HOURLY",
+		"INTERVAL=2",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=3",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYDAY=MO",
+		"FREQ=WEEKLY;BYMONTHDAY=5",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYMONTHDAY=-32",
+	}
+
+	for _, rule := range rules {
+		got, err := Parse(rule)
+
+		var scheduleErr *ScheduleError
+		if !errors.As(err, &scheduleErr) {
+			t.Errorf("Parse(%q) = %+v, %v, want a schedule error", rule, got, err)
+		}
+	}
+}
+
+func date(month time.Month, day int) time.Time {
+	return time.Date(2021, month, day, 9, 30, 0, 0, time.UTC)
+}
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		after time.Time
+		until time.Time
+		want  []time.Time
+	}{
+		{
+			name:  "last day of the month",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=-1",
+			start: date(time.January, 10),
+			until: date(time.April, 30),
+			want:  []time.Time{date(time.January, 31), date(time.February, 28), date(time.March, 31), date(time.April, 30)},
+		},
+		{
+			name:  "second to last day of the month",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=-2",
+			start: date(time.January, 10),
+			until: date(time.March, 1),
+			want:  []time.Time{date(time.January, 30), date(time.February, 27)},
+		},
+		{
+			name:  "day missing from the month",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=31",
+			start: date(time.January, 1),
+			until: date(time.April, 1),
+			want:  []time.Time{date(time.January, 31), date(time.February, 28), date(time.March, 31)},
+		},
+		{
+			name:  "days across week boundaries",
+			rule:  "FREQ=WEEKLY;BYDAY=SU,MO",
+			start: date(time.February, 20),
+			until: date(time.March, 1),
+			want:  []time.Time{date(time.February, 21), date(time.February, 22), date(time.February, 28), date(time.March, 1)},
+		},
+		{
+			name:  "every other week",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=FR",
+			start: date(time.February, 5),
+			until: date(time.March, 18),
+			want:  []time.Time{date(time.February, 5), date(time.February, 19), date(time.March, 5)},
+		},
+		{
+			name:  "every third day",
+			rule:  "FREQ=DAILY;INTERVAL=3",
+			start: date(time.February, 26),
+			until: date(time.March, 7),
+			want:  []time.Time{date(time.February, 26), date(time.March, 1), date(time.March, 4), date(time.March, 7)},
+		},
+		{
+			name:  "occurrence before the start",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=5",
+			start: date(time.January, 20),
+			until: date(time.March, 31),
+			want:  []time.Time{date(time.February, 5), date(time.March, 5)},
+		},
+		{
+			name:  "catching up after the last occurrence",
+			rule:  "FREQ=MONTHLY",
+			start: date(time.January, 1),
+			after: date(time.February, 1),
+			until: date(time.April, 15),
+			want:  []time.Time{date(time.March, 1), date(time.April, 1)},
+		},
+		{
+			name:  "nothing due",
+			rule:  "FREQ=YEARLY",
+			start: date(time.January, 1),
+			after: date(time.January, 1),
+			until: date(time.December, 31),
+			want:  nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule, err := Parse(test.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned an error: %v", test.rule, err)
+			}
+
+			got := schedule.Between(test.start, test.after, test.until)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Between = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/audit"
+	"github.com/jbonadiman/finances-api/internal/databases"
+	"github.com/jbonadiman/finances-api/internal/entities"
+	"github.com/jbonadiman/finances-api/internal/models"
+	"github.com/jbonadiman/finances-api/internal/recurrence"
+	"github.com/jbonadiman/finances-api/internal/webhooks"
+)
+
+// RecurringTaskID keys the transactions of a rule on the rule and the day of
+// the occurrence, so materializing the same occurrence twice never creates
+// duplicates.
+const RecurringTaskID = "recurring:%v:%v"
+
+// MaterializeRecurringRules stores every occurrence of the recurring rules
+// that is due by now and was not stored yet, including the ones missed while
+// nothing was running.
+func MaterializeRecurringRules(now time.Time) error {
+	ruleRepository, err := databases.GetRecurringRuleRepository()
+	if err != nil {
+		return err
+	}
+
+	transactionRepository, err := databases.GetTransactionRepository()
+	if err != nil {
+		return err
+	}
+
+	webhookRepository, err := databases.GetWebhookRepository()
+	if err != nil {
+		return err
+	}
+
+	auditRepository, err := databases.GetAuditRepository()
+	if err != nil {
+		return err
+	}
+
+	rules, err := ruleRepository.GetAllRecurringRules()
+	if err != nil {
+		return err
+	}
+
+	categoryOf := loadCategories()
+
+	var stored models.StoreResult
+	for _, rule := range *rules {
+		transactions, until, err := Occurrences(&rule, now)
+		if err != nil {
+			log.Printf("could not materialize rule %q: %v\n", rule.Description, err)
+			continue
+		}
+
+		for i := range transactions {
+			transactions[i].Category = categoryOf[rule.Subcategory]
+		}
+
+		result, err := transactionRepository.StoreTransactions(transactions...)
+		audit.Record(
+			auditRepository,
+			entities.AuditSourceRecurring,
+			"",
+			audit.StoredChanges(transactions, result)...,
+		)
+		webhooks.PublishStored(webhookRepository, transactions, result)
+		if err != nil {
+			return err
+		}
+
+		stored.Add(result)
+
+		err = ruleRepository.SetMaterializedUntil(rule.ID, until)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Printf(
+		"materialized recurring rules: %v new, %v already stored\n",
+		stored.New,
+		stored.AlreadyIngested,
+	)
+
+	return nil
+}
+
+// Occurrences builds the transactions of the rule due by now that were not
+// materialized yet, returning up to when they were built.
+func Occurrences(rule *entities.RecurringRule, now time.Time) (
+	[]entities.Transaction,
+	time.Time,
+	error,
+) {
+	schedule, err := recurrence.Parse(rule.Schedule)
+	if err != nil {
+		return nil, rule.MaterializedUntil, err
+	}
+
+	after := rule.MaterializedUntil
+	if after.IsZero() {
+		after = rule.Start.Add(-time.Nanosecond)
+	}
+
+	until := now
+	if rule.End != nil && rule.End.Before(until) {
+		until = *rule.End
+	}
+
+	if !until.After(after) {
+		return nil, rule.MaterializedUntil, nil
+	}
+
+	var transactions []entities.Transaction
+	for _, date := range schedule.Between(rule.Start, after, until) {
+		transactions = append(transactions, entities.Transaction{
+			ID: primitive.NewObjectID(),
+			OriginalTaskID: fmt.Sprintf(
+				RecurringTaskID,
+				rule.ID.Hex(),
+				date.Format(models.IntervalLayouts[models.IntervalDay]),
+			),
+			Date:          date,
+			CreatedAt:     now,
+			ModifiedAt:    rule.ModifiedAt,
+			Description:   rule.Description,
+			Cost:          rule.Amount,
+			Direction:     rule.Direction,
+			Subcategory:   rule.Subcategory,
+			CategorizedBy: "recurring:" + rule.ID.Hex(),
+			Account:       rule.Account,
+			AccountID:     rule.AccountID,
+		})
+	}
+
+	return transactions, until, nil
+}
+
+// loadCategories maps subcategories to their categories, transactions are
+// stored without a category when it cannot be loaded.
+func loadCategories() map[string]string {
+	subcategoryRepository, err := databases.GetSubcategoryRepository()
+	if err != nil {
+		return nil
+	}
+
+	categoryRepository, err := databases.GetCategoryRepository()
+	if err != nil {
+		return nil
+	}
+
+	subcategories, err := subcategoryRepository.GetAllSubcategories()
+	if err != nil {
+		log.Printf("could not load subcategories: %v\n", err)
+		return nil
+	}
+
+	categories, err := categoryRepository.GetAllCategories()
+	if err != nil {
+		log.Printf("could not load categories: %v\n", err)
+		return nil
+	}
+
+	return models.CategoriesBySubcategory(*subcategories, *categories)
+}
@@ -0,0 +1,34 @@
+// Package scheduler runs the background jobs of the long running server.
+// Serverless deployments have no process to run them in, so each job also
+// has an endpoint that triggers it.
+package scheduler
+
+import (
+	"log"
+	"time"
+)
+
+// Job runs once at the given time.
+type Job func(now time.Time) error
+
+// Every runs the job right away and then on every interval, forever. Running
+// right away catches up with anything missed while the server was down.
+func Every(interval time.Duration, name string, job Job) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	run(name, job, time.Now().UTC())
+
+	for now := range ticker.C {
+		run(name, job, now.UTC())
+	}
+}
+
+func run(name string, job Job, now time.Time) {
+	log.Printf("running scheduled job %q...\n", name)
+
+	err := job(now)
+	if err != nil {
+		log.Printf("scheduled job %q failed: %v\n", name, err)
+	}
+}
@@ -0,0 +1,19 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/jbonadiman/finances-api/internal/databases"
+	"github.com/jbonadiman/finances-api/internal/webhooks"
+)
+
+// RetryWebhookDeliveries sends again the webhook deliveries that are due by
+// now.
+func RetryWebhookDeliveries(now time.Time) error {
+	repository, err := databases.GetWebhookRepository()
+	if err != nil {
+		return err
+	}
+
+	return webhooks.RetryDue(repository, now)
+}
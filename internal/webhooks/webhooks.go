@@ -0,0 +1,381 @@
+// Package webhooks announces changes to the transactions to the URLs
+// registered for them. Every event is stored as a delivery per webhook before
+// it is sent, so failed deliveries are retried later with an exponential
+// backoff until MaxAttempts is reached. Publishing only waits for the
+// deliveries to be stored, they are sent in the background so a slow URL
+// never holds the change being announced.
+//
+// The body of a delivery is a JSON envelope with the delivery ID, the event,
+// when it happened and its data. It is signed with the secret of the webhook
+// using HMAC-SHA256, sent hex encoded in the SignatureHeader as
+// "sha256=<signature>".
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+	"github.com/jbonadiman/finances-api/internal/models"
+)
+
+const (
+	EventHeader     = "X-Finances-Event"
+	DeliveryHeader  = "X-Finances-Delivery"
+	SignatureHeader = "X-Finances-Signature"
+	SignaturePrefix = "sha256="
+
+	MaxAttempts   = 10
+	RetryBatch    = 100
+	RetryInterval = time.Minute
+	SendTimeOut   = 5 * time.Second
+
+	firstBackoff = time.Minute
+	maxBackoff   = 6 * time.Hour
+	secretBytes  = 32
+)
+
+var client = &http.Client{Timeout: SendTimeOut}
+
+// Repository keeps the webhooks and their deliveries.
+type Repository interface {
+	GetAllWebhooks() (*[]entities.Webhook, error)
+	StoreDeliveries(deliveries ...entities.WebhookDelivery) error
+	UpdateDelivery(delivery *entities.WebhookDelivery) error
+	GetDueDeliveries(now time.Time, limit int) (*[]entities.WebhookDelivery, error)
+}
+
+type envelope struct {
+	ID        primitive.ObjectID `json:"id"`
+	Event     string             `json:"event"`
+	CreatedAt time.Time          `json:"createdAt"`
+	Data      interface{}        `json:"data"`
+}
+
+// TransactionsData is the data of the transaction events.
+type TransactionsData struct {
+	Transactions []entities.Transaction `json:"transactions"`
+}
+
+// Publish stores a delivery of the event for every active webhook subscribed
+// to it and sends them in the background, without waiting for them. The ones
+// that do not finish are sent again by RetryDue. Nothing is sent without a
+// repository, as webhooks are not available, and failures are only logged,
+// so publishing never gets in the way of the change being announced.
+func Publish(repository Repository, event string, data interface{}) {
+	if repository == nil {
+		return
+	}
+
+	webhooks, err := repository.GetAllWebhooks()
+	if err != nil {
+		log.Printf("could not load webhooks to publish %q: %v\n", event, err)
+		return
+	}
+
+	now := time.Now().UTC()
+
+	var targets []*entities.Webhook
+	var deliveries []entities.WebhookDelivery
+
+	for i := range *webhooks {
+		webhook := &(*webhooks)[i]
+		if !webhook.Subscribes(event) {
+			continue
+		}
+
+		delivery, err := newDelivery(webhook, event, data, now)
+		if err != nil {
+			log.Printf("could not encode %q for webhook %v: %v\n", event, webhook.ID.Hex(), err)
+			return
+		}
+
+		targets = append(targets, webhook)
+		deliveries = append(deliveries, *delivery)
+	}
+
+	if len(deliveries) == 0 {
+		return
+	}
+
+	err = repository.StoreDeliveries(deliveries...)
+	if err != nil {
+		log.Printf("could not store the deliveries of %q: %v\n", event, err)
+		return
+	}
+
+	go deliverAll(repository, targets, deliveries, now)
+}
+
+// PublishTransactions publishes a transaction event, unless there are no
+// transactions to announce.
+func PublishTransactions(
+	repository Repository,
+	event string,
+	transactions []entities.Transaction,
+) {
+	if len(transactions) == 0 {
+		return
+	}
+
+	Publish(repository, event, TransactionsData{Transactions: transactions})
+}
+
+// PublishStored announces the transactions the store result reports as
+// created or updated, under the IDs they are stored with.
+func PublishStored(
+	repository Repository,
+	transactions []entities.Transaction,
+	result models.StoreResult,
+) {
+	byTaskID := make(map[string]entities.Transaction, len(transactions))
+	for _, t := range transactions {
+		byTaskID[t.OriginalTaskID] = t
+	}
+
+	var created, updated []entities.Transaction
+	for _, change := range result.Changes {
+		t, found := byTaskID[change.TaskID]
+		if !found {
+			continue
+		}
+
+		t.ID = change.ID
+
+		if change.New {
+			created = append(created, t)
+		} else {
+			updated = append(updated, t)
+		}
+	}
+
+	PublishTransactions(repository, entities.EventTransactionCreated, created)
+	PublishTransactions(repository, entities.EventTransactionUpdated, updated)
+}
+
+// RetryDue sends again the pending deliveries whose backoff is over by now.
+// Deliveries of webhooks that were deleted or deactivated since are given up.
+func RetryDue(repository Repository, now time.Time) error {
+	deliveries, err := repository.GetDueDeliveries(now, RetryBatch)
+	if err != nil {
+		return err
+	}
+
+	if len(*deliveries) == 0 {
+		return nil
+	}
+
+	webhooks, err := repository.GetAllWebhooks()
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[primitive.ObjectID]*entities.Webhook, len(*webhooks))
+	for i := range *webhooks {
+		byID[(*webhooks)[i].ID] = &(*webhooks)[i]
+	}
+
+	var targets []*entities.Webhook
+	var due []entities.WebhookDelivery
+
+	for _, delivery := range *deliveries {
+		webhook, found := byID[delivery.WebhookID]
+		if !found || !webhook.Active {
+			delivery.Status = entities.DeliveryFailed
+			delivery.LastError = "the webhook was deleted or deactivated"
+			delivery.NextAttemptAt = nil
+
+			err = repository.UpdateDelivery(&delivery)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		targets = append(targets, webhook)
+		due = append(due, delivery)
+	}
+
+	log.Printf("retrying %v webhook deliveries\n", len(due))
+	deliverAll(repository, targets, due, now)
+
+	return nil
+}
+
+// Sign returns the signature of the payload with the secret, as sent in the
+// SignatureHeader.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+
+	return SignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSecret returns a random secret to sign the deliveries of a webhook.
+func NewSecret() (string, error) {
+	secret := make([]byte, secretBytes)
+
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(secret), nil
+}
+
+// Backoff is how long to wait before the next attempt after the given number
+// of failed ones.
+func Backoff(attempts int) time.Duration {
+	backoff := firstBackoff
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxBackoff {
+		return maxBackoff
+	}
+
+	return backoff
+}
+
+func newDelivery(
+	webhook *entities.Webhook,
+	event string,
+	data interface{},
+	now time.Time,
+) (*entities.WebhookDelivery, error) {
+	id := primitive.NewObjectID()
+
+	// the first attempt is made right away, the retry only covers it not
+	// finishing, such as the process stopping before it does
+	retryAt := now.Add(Backoff(1))
+
+	payload, err := json.Marshal(
+		envelope{
+			ID:        id,
+			Event:     event,
+			CreatedAt: now,
+			Data:      data,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entities.WebhookDelivery{
+		ID:            id,
+		WebhookID:     webhook.ID,
+		Event:         event,
+		Payload:       string(payload),
+		Status:        entities.DeliveryPending,
+		CreatedAt:     now,
+		NextAttemptAt: &retryAt,
+	}, nil
+}
+
+// deliverAll sends the deliveries at the same time and stores the outcome
+// of each one.
+func deliverAll(
+	repository Repository,
+	webhooks []*entities.Webhook,
+	deliveries []entities.WebhookDelivery,
+	now time.Time,
+) {
+	var wg sync.WaitGroup
+
+	for i := range deliveries {
+		wg.Add(1)
+
+		go func(webhook *entities.Webhook, delivery *entities.WebhookDelivery) {
+			defer wg.Done()
+
+			deliver(webhook, delivery, now)
+
+			err := repository.UpdateDelivery(delivery)
+			if err != nil {
+				log.Printf("could not store delivery %v: %v\n", delivery.ID.Hex(), err)
+			}
+		}(webhooks[i], &deliveries[i])
+	}
+
+	wg.Wait()
+}
+
+// deliver sends the delivery once and records the outcome, scheduling the
+// next attempt when it failed and attempts are left.
+func deliver(webhook *entities.Webhook, delivery *entities.WebhookDelivery, now time.Time) {
+	delivery.Attempts++
+
+	status, err := send(webhook, delivery)
+	delivery.ResponseStatus = status
+
+	switch {
+	case err == nil:
+		delivery.Status = entities.DeliveryDelivered
+		deliveredAt := time.Now().UTC()
+		delivery.LastError = ""
+		delivery.DeliveredAt = &deliveredAt
+		delivery.NextAttemptAt = nil
+	case delivery.Attempts >= MaxAttempts:
+		delivery.Status = entities.DeliveryFailed
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = nil
+	default:
+		delivery.LastError = err.Error()
+		nextAttemptAt := now.Add(Backoff(delivery.Attempts))
+		delivery.NextAttemptAt = &nextAttemptAt
+	}
+
+	if err != nil {
+		log.Printf(
+			"delivery %v of %q to %v failed (attempt %v): %v\n",
+			delivery.ID.Hex(),
+			delivery.Event,
+			webhook.URL,
+			delivery.Attempts,
+			err,
+		)
+	}
+}
+
+func send(webhook *entities.Webhook, delivery *entities.WebhookDelivery) (int, error) {
+	payload := []byte(delivery.Payload)
+
+	request, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(EventHeader, delivery.Event)
+	request.Header.Set(DeliveryHeader, delivery.ID.Hex())
+	request.Header.Set(SignatureHeader, Sign(webhook.Secret, payload))
+
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	_, _ = io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("unexpected status %v", response.Status)
+	}
+
+	return response.StatusCode, nil
+}
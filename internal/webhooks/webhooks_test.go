@@ -0,0 +1,247 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/jbonadiman/finances-api/internal/entities"
+)
+
+func TestSign(t *testing.T) {
+	got := Sign("secret", []byte(`{"event":"ingestion.finished"}`))
+	want := "sha256=6f342d6f2f8a8bc95fc00872340113099af52c859a111e8a44e777c792e1851d"
+
+	if got != want {
+		t.Errorf("Sign() = %q, want %q", got, want)
+	}
+
+	if other := Sign("other", []byte(`{"event":"ingestion.finished"}`)); other == got {
+		t.Errorf("signatures with different secrets are the same: %q", other)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 0, want: time.Minute},
+		{attempts: 1, want: time.Minute},
+		{attempts: 2, want: 2 * time.Minute},
+		{attempts: 3, want: 4 * time.Minute},
+		{attempts: 9, want: 256 * time.Minute},
+		{attempts: 10, want: 6 * time.Hour},
+		{attempts: 1000, want: 6 * time.Hour},
+	}
+
+	for _, test := range tests {
+		if got := Backoff(test.attempts); got != test.want {
+			t.Errorf("Backoff(%v) = %v, want %v", test.attempts, got, test.want)
+		}
+	}
+}
+
+// newReceiver serves webhook deliveries, checking they are signed with the
+// secret and answering with the statuses in turn.
+func newReceiver(t *testing.T, secret string, statuses ...int) (*httptest.Server, *int) {
+	t.Helper()
+
+	var mu sync.Mutex
+	received := 0
+
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				t.Errorf("could not read the delivery: %v", err)
+			}
+
+			if got, want := r.Header.Get(SignatureHeader), Sign(secret, body); got != want {
+				t.Errorf("got the signature %q, want %q", got, want)
+			}
+
+			var payload envelope
+			if err := json.Unmarshal(body, &payload); err != nil {
+				t.Errorf("could not decode the delivery: %v", err)
+			}
+
+			if got := r.Header.Get(EventHeader); got != payload.Event {
+				t.Errorf("got the event header %q, want %q", got, payload.Event)
+			}
+
+			if got := r.Header.Get(DeliveryHeader); got != payload.ID.Hex() {
+				t.Errorf("got the delivery header %q, want %q", got, payload.ID.Hex())
+			}
+
+			mu.Lock()
+			status := statuses[received%len(statuses)]
+			received++
+			mu.Unlock()
+
+			w.WriteHeader(status)
+		}),
+	)
+	t.Cleanup(server.Close)
+
+	return server, &received
+}
+
+func newWebhook(url string) *entities.Webhook {
+	return &entities.Webhook{
+		ID:     primitive.NewObjectID(),
+		URL:    url,
+		Secret: "secret",
+		Active: true,
+	}
+}
+
+func TestDeliverRetriesAfterFailure(t *testing.T) {
+	server, received := newReceiver(t, "secret", http.StatusInternalServerError, http.StatusOK)
+	webhook := newWebhook(server.URL)
+	now := time.Date(2021, 2, 15, 10, 0, 0, 0, time.UTC)
+
+	delivery, err := newDelivery(webhook, entities.EventIngestionFinished, map[string]int{"new": 1}, now)
+	if err != nil {
+		t.Fatalf("newDelivery returned an error: %v", err)
+	}
+
+	deliver(webhook, delivery, now)
+
+	retryAt := now.Add(Backoff(1))
+	switch {
+	case delivery.Status != entities.DeliveryPending:
+		t.Errorf("failed delivery is %q, want %q", delivery.Status, entities.DeliveryPending)
+	case delivery.Attempts != 1 || delivery.ResponseStatus != http.StatusInternalServerError:
+		t.Errorf("failed delivery made %v attempts answered with %v", delivery.Attempts, delivery.ResponseStatus)
+	case delivery.LastError == "":
+		t.Errorf("failed delivery has no error")
+	case delivery.NextAttemptAt == nil || !delivery.NextAttemptAt.Equal(retryAt):
+		t.Errorf("failed delivery is retried at %v, want %v", delivery.NextAttemptAt, retryAt)
+	}
+
+	deliver(webhook, delivery, retryAt)
+
+	switch {
+	case delivery.Status != entities.DeliveryDelivered:
+		t.Errorf("retried delivery is %q, want %q", delivery.Status, entities.DeliveryDelivered)
+	case delivery.Attempts != 2 || delivery.ResponseStatus != http.StatusOK:
+		t.Errorf("retried delivery made %v attempts answered with %v", delivery.Attempts, delivery.ResponseStatus)
+	case delivery.LastError != "" || delivery.DeliveredAt == nil || delivery.NextAttemptAt != nil:
+		t.Errorf("retried delivery = %+v, want it delivered", delivery)
+	}
+
+	if *received != 2 {
+		t.Errorf("the receiver got %v deliveries, want 2", *received)
+	}
+}
+
+func TestDeliverGivesUpAfterMaxAttempts(t *testing.T) {
+	server, _ := newReceiver(t, "secret", http.StatusInternalServerError)
+	webhook := newWebhook(server.URL)
+	now := time.Date(2021, 2, 15, 10, 0, 0, 0, time.UTC)
+
+	delivery, err := newDelivery(webhook, entities.EventIngestionFinished, nil, now)
+	if err != nil {
+		t.Fatalf("newDelivery returned an error: %v", err)
+	}
+
+	for i := 1; i < MaxAttempts; i++ {
+		deliver(webhook, delivery, now)
+
+		if delivery.Status != entities.DeliveryPending {
+			t.Fatalf("delivery is %q after %v attempts, want it pending", delivery.Status, i)
+		}
+	}
+
+	deliver(webhook, delivery, now)
+
+	if delivery.Status != entities.DeliveryFailed || delivery.NextAttemptAt != nil {
+		t.Errorf("delivery = %+v after %v attempts, want it failed", delivery, MaxAttempts)
+	}
+}
+
+// fakeRepository keeps the webhooks in memory and reports every delivery
+// update on updated.
+type fakeRepository struct {
+	webhooks   []entities.Webhook
+	stored     []entities.WebhookDelivery
+	updated    chan entities.WebhookDelivery
+	deliveries []entities.WebhookDelivery
+}
+
+func (r *fakeRepository) GetAllWebhooks() (*[]entities.Webhook, error) {
+	return &r.webhooks, nil
+}
+
+func (r *fakeRepository) StoreDeliveries(deliveries ...entities.WebhookDelivery) error {
+	r.stored = append(r.stored, deliveries...)
+	return nil
+}
+
+func (r *fakeRepository) UpdateDelivery(delivery *entities.WebhookDelivery) error {
+	r.updated <- *delivery
+	return nil
+}
+
+func (r *fakeRepository) GetDueDeliveries(time.Time, int) (*[]entities.WebhookDelivery, error) {
+	return &r.deliveries, nil
+}
+
+func TestPublish(t *testing.T) {
+	server, _ := newReceiver(t, "secret", http.StatusOK)
+
+	subscribed := newWebhook(server.URL)
+	subscribed.Events = []string{entities.EventIngestionFinished}
+
+	other := newWebhook(server.URL)
+	other.Events = []string{entities.EventTransactionCreated}
+
+	inactive := newWebhook(server.URL)
+	inactive.Active = false
+
+	repository := &fakeRepository{
+		webhooks: []entities.Webhook{*subscribed, *other, *inactive},
+		updated:  make(chan entities.WebhookDelivery, 3),
+	}
+
+	Publish(repository, entities.EventIngestionFinished, map[string]int{"new": 1})
+
+	if len(repository.stored) != 1 || repository.stored[0].WebhookID != subscribed.ID {
+		t.Fatalf("stored the deliveries %+v, want one to %v", repository.stored, subscribed.ID.Hex())
+	}
+
+	select {
+	case delivery := <-repository.updated:
+		if delivery.Status != entities.DeliveryDelivered {
+			t.Errorf("delivery = %+v, want it delivered", delivery)
+		}
+	case <-time.After(SendTimeOut):
+		t.Fatalf("the delivery was not sent")
+	}
+}
+
+func TestRetryDueGivesUpDeletedWebhooks(t *testing.T) {
+	repository := &fakeRepository{
+		updated: make(chan entities.WebhookDelivery, 1),
+		deliveries: []entities.WebhookDelivery{
+			{ID: primitive.NewObjectID(), WebhookID: primitive.NewObjectID(), Status: entities.DeliveryPending},
+		},
+	}
+
+	err := RetryDue(repository, time.Now().UTC())
+	if err != nil {
+		t.Fatalf("RetryDue returned an error: %v", err)
+	}
+
+	delivery := <-repository.updated
+	if delivery.Status != entities.DeliveryFailed || delivery.NextAttemptAt != nil {
+		t.Errorf("delivery = %+v, want it failed", delivery)
+	}
+}
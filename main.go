@@ -2,8 +2,12 @@ package main
 
 import (
 	"net/http"
+	"time"
 
 	handler "github.com/jbonadiman/finances-api/api"
+	"github.com/jbonadiman/finances-api/internal/environment"
+	"github.com/jbonadiman/finances-api/internal/scheduler"
+	"github.com/jbonadiman/finances-api/internal/webhooks"
 )
 
 func main() {
@@ -16,6 +20,36 @@ func main() {
 	http.HandleFunc("/api/auth", handler.StoreToken)
 	http.HandleFunc("/api/get-tasks", handler.FetchTasks)
 	http.HandleFunc("/api/query", handler.QueryTransactions)
+	http.HandleFunc("/api/transactions", handler.ManageTransaction)
+	http.HandleFunc("/api/restore-transaction", handler.RestoreTransaction)
+	http.HandleFunc("/api/transaction-history", handler.GetTransactionHistory)
+	http.HandleFunc("/api/summary", handler.SummarizeTransactions)
+	http.HandleFunc("/api/subcategories", handler.ManageSubcategories)
+	http.HandleFunc("/api/merge-subcategories", handler.MergeSubcategories)
+	http.HandleFunc("/api/categories", handler.ManageCategories)
+	http.HandleFunc("/api/accounts", handler.ManageAccounts)
+	http.HandleFunc("/api/balances", handler.GetBalances)
+	http.HandleFunc("/api/installments", handler.ManageInstallments)
+	http.HandleFunc("/api/recurring-rules", handler.ManageRecurringRules)
+	http.HandleFunc("/api/materialize-recurring", handler.MaterializeRecurring)
+	http.HandleFunc("/api/budgets", handler.ManageBudgets)
+	http.HandleFunc("/api/budgets/status", handler.GetBudgetStatus)
+	http.HandleFunc("/api/webhooks", handler.ManageWebhooks)
+	http.HandleFunc("/api/webhook-deliveries", handler.ManageWebhookDeliveries)
+
+	if environment.StorageBackend == environment.MongoBackend {
+		go scheduler.Every(
+			time.Duration(environment.RecurringInterval)*time.Minute,
+			"materialize recurring rules",
+			scheduler.MaterializeRecurringRules,
+		)
+
+		go scheduler.Every(
+			webhooks.RetryInterval,
+			"retry webhook deliveries",
+			scheduler.RetryWebhookDeliveries,
+		)
+	}
 
 	http.ListenAndServe(":8080", nil)
 }